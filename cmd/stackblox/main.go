@@ -4,7 +4,11 @@ import (
 	"fmt"
 
 	"Backend/models"
+	"Backend/pkg/functions"
 	"Backend/pkg/projects"
+	_ "Backend/pkg/runtimes/golang"
+	_ "Backend/pkg/runtimes/node"
+	_ "Backend/pkg/runtimes/python"
 	"Backend/utils"
 	"github.com/gin-gonic/gin"
 	ginlogrus "github.com/toorop/gin-logrus"
@@ -35,8 +39,22 @@ func init() {
 		&models.Project{},
 		&models.Function{},
 		&models.Database{},
+		&models.Build{},
+		&models.Alias{},
+		&models.TrafficRule{},
+		&models.Invocation{},
 	)
 	if err != nil {
 		utils.Logger.Fatalf("failed to run the databases migrations: %v", err)
 	}
+
+	err = functions.FailInterruptedBuilds()
+	if err != nil {
+		utils.Logger.Fatalf("failed to mark the interrupted builds as failed: %v", err)
+	}
+
+	err = functions.MigrateLegacyImageTags()
+	if err != nil {
+		utils.Logger.Fatalf("failed to migrate the legacy function images: %v", err)
+	}
 }
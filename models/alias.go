@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Alias is a named pointer, such as prod or beta, to a version of a function.
+type Alias struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+	ProjectId  uuid.UUID `gorm:"not null;type:varchar(255);uniqueIndex:project_function_alias_index" json:"projectId"`
+	FunctionId string    `gorm:"not null;type:varchar(255);uniqueIndex:project_function_alias_index" json:"functionId"`
+	Name       string    `gorm:"not null;type:varchar(255);uniqueIndex:project_function_alias_index" json:"name"`
+	Version    string    `gorm:"not null;type:varchar(255)" json:"version"`
+}
+
+type SetAliasDTO struct {
+	Version string `json:"version" binding:"required"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BuildStatus string
+
+const (
+	BuildQueued    BuildStatus = "queued"
+	BuildBuilding  BuildStatus = "building"
+	BuildSucceeded BuildStatus = "succeeded"
+	BuildFailed    BuildStatus = "failed"
+)
+
+type Build struct {
+	ID         uuid.UUID   `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	CreatedAt  time.Time   `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt  time.Time   `gorm:"autoUpdateTime" json:"updatedAt"`
+	ProjectId  uuid.UUID   `gorm:"not null;type:varchar(255);index" json:"projectId"`
+	FunctionId string      `gorm:"not null;type:varchar(255)" json:"functionId"`
+	Version    string      `gorm:"not null;type:varchar(255)" json:"version"`
+	Status     BuildStatus `gorm:"not null;type:varchar(255)" json:"status"`
+	Cached     bool        `gorm:"not null;default:false" json:"cached"`
+	Error      string      `gorm:"type:text" json:"error,omitempty"`
+	FailedStep string      `gorm:"type:text" json:"failedStep,omitempty"`
+	LogExcerpt string      `gorm:"type:text" json:"logExcerpt,omitempty"`
+	Logs       string      `gorm:"type:text" json:"-"`
+	StartedAt  *time.Time  `json:"startedAt"`
+	FinishedAt *time.Time  `json:"finishedAt"`
+}
@@ -20,6 +20,25 @@ type Function struct {
 	Version     string         `gorm:"not null;type:varchar(255);primaryKey" json:"version" containerEnv:"include"`
 	ProjectId   uuid.UUID      `gorm:"not null;type:varchar(255);primaryKey;foreignKey:ID" json:"projectId"`
 
+	RuntimeVersion   string `gorm:"type:varchar(255)" json:"runtimeVersion"`
+	CustomDockerfile bool   `gorm:"not null;default:false" json:"customDockerfile"`
+	MinInstances     int    `gorm:"not null;default:0" json:"minInstances"`
+	MaxInstances     int    `gorm:"not null;default:0" json:"maxInstances"`
+	// HealthEndpoint is the path the containers of the function answer 200 on once ready.
+	// It is empty for the functions deployed before it was recorded, which answer on /health.
+	HealthEndpoint string `gorm:"type:varchar(255)" json:"healthEndpoint"`
+	// TimeoutMs bounds the duration of an invocation, the platform default applying when it is 0.
+	TimeoutMs int64 `gorm:"not null;default:0" json:"timeoutMs"`
+	// SourceHash identifies the deployed content: the sha256 of the uploaded tarball
+	// or image archive, or the image ID of the images pulled from the registry.
+	SourceHash  string `gorm:"type:varchar(255)" json:"sourceHash"`
+	ImageDigest string `gorm:"type:varchar(255)" json:"imageDigest"`
+	// BuildHash identifies the source the image was built from, along with the
+	// files the engine generated into it. Images built from the same hash are reused.
+	BuildHash string `gorm:"type:varchar(255)" json:"buildHash"`
+
+	ResourceLimits `gorm:"embedded"`
+
 	Project  Project
 	Language Language
 	Main     string
@@ -31,4 +50,34 @@ type Definition struct {
 	Version     string `json:"version"`
 	Main        string `json:"main"`
 	//... other fields as needed ...
+
+	Engines map[string]string `json:"engines"`
+	// RuntimeVersion is the version constraint of the language, read by the runtime
+	// from the field its ecosystem uses, such as engines.node for JavaScript.
+	RuntimeVersion string `json:"-"`
+
+	Stackblox DefinitionSettings `json:"stackblox"`
+}
+
+// DefinitionSettings holds the engine specific settings declared in the definition file.
+type DefinitionSettings struct {
+	MinInstances int `json:"minInstances" toml:"minInstances"`
+	MaxInstances int `json:"maxInstances" toml:"maxInstances"`
+	// Timeout is the longest an invocation may take, such as "30s".
+	Timeout string `json:"timeout" toml:"timeout"`
+
+	ResourceSettings
+}
+
+type DeployImageDTO struct {
+	Name         string `form:"name" binding:"required"`
+	Version      string `form:"version" binding:"required"`
+	Description  string `form:"description"`
+	Reference    string `form:"reference"`
+	MinInstances int    `form:"minInstances"`
+	MaxInstances int    `form:"maxInstances"`
+	Timeout      string `form:"timeout"`
+	Force        bool   `form:"force"`
+
+	ResourceSettings
 }
@@ -16,8 +16,12 @@ type Project struct {
 	NetworkName string         `gorm:"not null;unique" json:"networkName"`
 	Functions   []Function     `gorm:"foreignKey:ProjectId;references:ID" json:"functions"`
 	Databases   []Database     `gorm:"foreignKey:ProjectId;references:ID" json:"databases"`
+	// Limits are the ceilings of the resource limits of the project functions,
+	// a zero value falling back to the ceiling of the platform.
+	Limits ResourceLimits `gorm:"embedded;embeddedPrefix:limit_" json:"limits"`
 }
 
 type CreateProjectDTO struct {
-	Name string `json:"name" binding:"required"`
+	Name   string            `json:"name" binding:"required"`
+	Limits *ResourceSettings `json:"limits"`
 }
@@ -0,0 +1,19 @@
+package models
+
+// ResourceSettings are the resource limits as declared by a function or a project,
+// sizes being written as "256m" or "1g".
+type ResourceSettings struct {
+	Memory    string `json:"memory" toml:"memory" form:"memory"`
+	CpuShares int64  `json:"cpuShares" toml:"cpuShares" form:"cpuShares"`
+	PidsLimit int64  `json:"pidsLimit" toml:"pidsLimit" form:"pidsLimit"`
+	TmpfsSize string `json:"tmpfsSize" toml:"tmpfsSize" form:"tmpfsSize"`
+}
+
+// ResourceLimits are the resources a function container may use. A zero value
+// leaves the corresponding resource to the defaults of the engine.
+type ResourceLimits struct {
+	MemoryBytes int64 `gorm:"not null;default:0" json:"memoryBytes"`
+	CpuShares   int64 `gorm:"not null;default:0" json:"cpuShares"`
+	PidsLimit   int64 `gorm:"not null;default:0" json:"pidsLimit"`
+	TmpfsBytes  int64 `gorm:"not null;default:0" json:"tmpfsBytes"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TrafficRule splits the invocations of a function, or of one of its aliases,
+// between versions. Alias is empty for the rule of the function itself.
+type TrafficRule struct {
+	ID           uuid.UUID       `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	CreatedAt    time.Time       `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt    time.Time       `gorm:"autoUpdateTime" json:"updatedAt"`
+	ProjectId    uuid.UUID       `gorm:"not null;type:varchar(255);uniqueIndex:project_function_traffic_index" json:"projectId"`
+	FunctionId   string          `gorm:"not null;type:varchar(255);uniqueIndex:project_function_traffic_index" json:"functionId"`
+	Alias        string          `gorm:"not null;type:varchar(255);default:'';uniqueIndex:project_function_traffic_index" json:"alias"`
+	Weights      []TrafficWeight `gorm:"not null;type:text;serializer:json" json:"weights"`
+	StickyHeader string          `gorm:"type:varchar(255)" json:"stickyHeader"`
+	StickyCookie string          `gorm:"type:varchar(255)" json:"stickyCookie"`
+}
+
+// TrafficWeight is the share of the invocations a version receives, relative to
+// the other weights of its rule.
+type TrafficWeight struct {
+	Version string `json:"version" binding:"required"`
+	Weight  int    `json:"weight" binding:"min=0"`
+}
+
+type SetTrafficRuleDTO struct {
+	Weights      []TrafficWeight `json:"weights" binding:"required,min=1,dive"`
+	StickyHeader string          `json:"stickyHeader"`
+	StickyCookie string          `json:"stickyCookie"`
+}
+
+// Invocation records the version that served a request to a function and its outcome.
+type Invocation struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;index" json:"createdAt"`
+	ProjectId  uuid.UUID `gorm:"not null;type:varchar(255);index:project_function_invocation_index" json:"projectId"`
+	FunctionId string    `gorm:"not null;type:varchar(255);index:project_function_invocation_index" json:"functionId"`
+	Version    string    `gorm:"not null;type:varchar(255)" json:"version"`
+	Alias      string    `gorm:"type:varchar(255)" json:"alias,omitempty"`
+	StatusCode int       `gorm:"not null" json:"statusCode"`
+	DurationMs int64     `gorm:"not null" json:"durationMs"`
+	TimedOut   bool      `gorm:"not null;default:false" json:"timedOut"`
+}
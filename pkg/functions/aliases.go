@@ -0,0 +1,311 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"Backend/models"
+	"Backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// latestVersion is the image version of the most recently deployed version of a function.
+const latestVersion = "latest"
+
+// aliasNamePattern restricts the alias names to identifiers that cannot be
+// mistaken for a version, since both are requested with the same header.
+var aliasNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// functionVersion is a deployed version of a function along with the aliases pointing at it.
+type functionVersion struct {
+	models.Function
+	Aliases []string `json:"aliases"`
+}
+
+// ListFunctionVersions lists the deployed versions of a function, newest first, a page at a time.
+func ListFunctionVersions(c *gin.Context) {
+	project, _ := utils.GetProjectFromContext(c)
+
+	page, pageSize, err := utils.GetPagination(c)
+	if utils.HandleError(c, http.StatusBadRequest, err, "invalid pagination") {
+		return
+	}
+
+	versions, total, _, err := listFunctionVersions(project.ID, c.Param("functionId"), page, pageSize)
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot list the function versions") {
+		return
+	}
+	if total == 0 {
+		utils.JsonError(c, http.StatusNotFound, fmt.Errorf("record not found"), "failed to find function")
+		return
+	}
+
+	utils.JsonSuccessH(
+		c,
+		http.StatusOK,
+		"function versions",
+		gin.H{
+			"versions": versions,
+			"page":     page,
+			"pageSize": pageSize,
+			"total":    total,
+		},
+	)
+}
+
+// ListFunctionAliases lists the aliases of a function.
+func ListFunctionAliases(c *gin.Context) {
+	project, _ := utils.GetProjectFromContext(c)
+
+	aliases, err := listAliases(project.ID, c.Param("functionId"))
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot list the function aliases") {
+		return
+	}
+
+	utils.JsonSuccessH(c, http.StatusOK, "function aliases", gin.H{"aliases": aliases})
+}
+
+// GetFunctionAlias returns an alias of a function.
+func GetFunctionAlias(c *gin.Context) {
+	alias, err := utils.GetAliasFromContextParams(c)
+	if utils.HandleError(c, http.StatusNotFound, err, "failed to find alias") {
+		return
+	}
+
+	utils.JsonSuccessH(c, http.StatusOK, "function alias", gin.H{"alias": alias})
+}
+
+// SetFunctionAlias points an alias at a version of a function, creating the alias
+// if needed. The alias is moved in a single statement, so invocations resolve
+// either the previous version or the new one, which makes rolling back a matter
+// of pointing the alias at the previous version again.
+func SetFunctionAlias(c *gin.Context) {
+	project, _ := utils.GetProjectFromContext(c)
+	functionId := c.Param("functionId")
+	name := c.Param("alias")
+
+	if !aliasNamePattern.MatchString(name) || name == latestVersion {
+		utils.JsonError(
+			c,
+			http.StatusBadRequest,
+			fmt.Errorf("invalid alias name %q", name),
+			"alias names start with a lowercase letter followed by lowercase letters, digits or dashes",
+		)
+		return
+	}
+
+	var setAliasRequest models.SetAliasDTO
+	err := c.ShouldBindJSON(&setAliasRequest)
+	if utils.HandleError(c, http.StatusBadRequest, err, "cannot parse input") {
+		return
+	}
+
+	_, err = findFunctionVersion(project.ID, functionId, setAliasRequest.Version)
+	if utils.HandleError(c, http.StatusNotFound, err, "failed to find function version") {
+		return
+	}
+
+	alias := models.Alias{
+		ProjectId:  project.ID,
+		FunctionId: functionId,
+		Name:       name,
+		Version:    setAliasRequest.Version,
+	}
+	previousVersion := ""
+
+	err = utils.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.Alias
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("function_id = ? AND name = ? AND project_id = ?", functionId, name, project.ID.String()).
+			First(&current).
+			Error
+		if err == nil {
+			previousVersion = current.Version
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		err = tx.
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "project_id"}, {Name: "function_id"}, {Name: "name"}},
+				DoUpdates: clause.AssignmentColumns([]string{"version", "updated_at"}),
+			}).
+			Create(&alias).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.
+			Where("function_id = ? AND name = ? AND project_id = ?", functionId, name, project.ID.String()).
+			First(&alias).
+			Error
+	})
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot set the alias") {
+		return
+	}
+
+	utils.JsonSuccessH(
+		c,
+		http.StatusOK,
+		"alias set",
+		gin.H{
+			"alias":           alias,
+			"previousVersion": previousVersion,
+		},
+	)
+}
+
+// DeleteFunctionAlias removes an alias of a function along with its traffic rule.
+// The versions it pointed at are left untouched.
+func DeleteFunctionAlias(c *gin.Context) {
+	alias, err := utils.GetAliasFromContextParams(c)
+	if utils.HandleError(c, http.StatusNotFound, err, "failed to find alias") {
+		return
+	}
+
+	err = deleteAlias(alias)
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot delete the alias") {
+		return
+	}
+
+	utils.JsonSuccessH(c, http.StatusOK, "alias deleted", gin.H{"alias": alias})
+}
+
+// deleteAlias deletes an alias and its traffic rule, which would otherwise keep
+// referencing versions with no way left to remove it.
+func deleteAlias(alias *models.Alias) error {
+	return utils.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Where("function_id = ? AND alias = ? AND project_id = ?", alias.FunctionId, alias.Name, alias.ProjectId.String()).
+			Delete(&models.TrafficRule{}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(alias).Error
+	})
+}
+
+// resolveFunctionVersion resolves the version requested through the x-image-version
+// header, which is either a version, an alias of the function or latest. Requests
+// not pinning a version, by sending no header or an alias, are routed by the traffic
+// rule of the function or of the alias when there is one. It returns the function
+// entity of the resolved version and the alias used, if any.
+func resolveFunctionVersion(c *gin.Context, latest *models.Function) (*models.Function, string, error) {
+	requested := c.GetHeader("x-image-version")
+	if requested == latestVersion || requested == latest.Version {
+		return latest, "", nil
+	}
+
+	aliasName := ""
+
+	if requested != "" {
+		var alias models.Alias
+		err := utils.DB.
+			Where("function_id = ? AND name = ? AND project_id = ?", latest.FunctionId, requested, latest.ProjectId.String()).
+			First(&alias).
+			Error
+		if err == nil {
+			aliasName = alias.Name
+			requested = alias.Version
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", err
+		}
+	}
+
+	if requested == "" || aliasName != "" {
+		rule, err := findTrafficRule(latest.ProjectId.String(), latest.FunctionId, aliasName)
+		if err != nil {
+			return nil, "", err
+		}
+		if rule != nil {
+			requested = pickVersion(c, rule)
+		}
+	}
+
+	if requested == "" {
+		return latest, "", nil
+	}
+
+	function, err := findFunctionVersion(latest.ProjectId, latest.FunctionId, requested)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot find version %s of function %s: %v", requested, latest.FunctionId, err)
+	}
+
+	return function, aliasName, nil
+}
+
+// listFunctionVersions returns a page of the versions of a function, newest first,
+// along with the aliases pointing at each of them, the total number of versions,
+// and the aliases of the function.
+func listFunctionVersions(projectId uuid.UUID, functionId string, page int, pageSize int) ([]functionVersion, int64, []models.Alias, error) {
+	query := utils.DB.
+		Model(&models.Function{}).
+		Where("function_id = ? AND project_id = ?", functionId, projectId.String()).
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, nil, err
+	}
+
+	var functions []models.Function
+	err := query.
+		Order("created_at desc").
+		Limit(pageSize).
+		Offset((page - 1) * pageSize).
+		Find(&functions).
+		Error
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	aliases, err := listAliases(projectId, functionId)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	versions := make([]functionVersion, 0, len(functions))
+	for _, function := range functions {
+		version := functionVersion{Function: function, Aliases: []string{}}
+		for _, alias := range aliases {
+			if alias.Version == function.Version {
+				version.Aliases = append(version.Aliases, alias.Name)
+			}
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, total, aliases, nil
+}
+
+// findFunctionVersion retrieves the function entity of a version of a function.
+func findFunctionVersion(projectId uuid.UUID, functionId string, version string) (*models.Function, error) {
+	var functionEntity *models.Function
+	err := utils.DB.
+		Where("function_id = ? AND version = ? AND project_id = ?", functionId, version, projectId.String()).
+		Preload("Project").
+		First(&functionEntity).
+		Error
+
+	return functionEntity, err
+}
+
+func listAliases(projectId uuid.UUID, functionId string) ([]models.Alias, error) {
+	aliases := []models.Alias{}
+	err := utils.DB.
+		Where("function_id = ? AND project_id = ?", functionId, projectId.String()).
+		Order("name").
+		Find(&aliases).
+		Error
+
+	return aliases, err
+}
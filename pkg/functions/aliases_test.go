@@ -0,0 +1,59 @@
+package functions
+
+import (
+	"testing"
+
+	"Backend/models"
+	"Backend/utils"
+)
+
+func TestDeleteAliasRemovesItsTrafficRule(t *testing.T) {
+	project := newTestProject(t)
+
+	canary := &models.Function{Name: "hello", FunctionId: "hello", Version: "2.0.0", ProjectId: project.ID}
+	alias := &models.Alias{ProjectId: project.ID, FunctionId: "hello", Name: "beta", Version: "1.0.0"}
+	aliasRule := &models.TrafficRule{
+		ProjectId:  project.ID,
+		FunctionId: "hello",
+		Alias:      alias.Name,
+		Weights:    []models.TrafficWeight{{Version: "1.0.0", Weight: 90}, {Version: canary.Version, Weight: 10}},
+	}
+	functionRule := &models.TrafficRule{
+		ProjectId:  project.ID,
+		FunctionId: "hello",
+		Weights:    []models.TrafficWeight{{Version: "1.0.0", Weight: 1}},
+	}
+	for _, record := range []interface{}{canary, alias, aliasRule, functionRule} {
+		if err := utils.DB.Create(record).Error; err != nil {
+			t.Fatalf("create %T: %v", record, err)
+		}
+	}
+
+	if err := checkVersionUnreferenced(canary); err == nil {
+		t.Fatalf("the version weighted by the alias rule is not referenced")
+	}
+
+	if err := deleteAlias(alias); err != nil {
+		t.Fatalf("deleteAlias: %v", err)
+	}
+
+	if err := checkVersionUnreferenced(canary); err != nil {
+		t.Errorf("the version is still referenced once the alias is deleted: %v", err)
+	}
+
+	rule, err := findTrafficRule(project.ID.String(), "hello", alias.Name)
+	if err != nil {
+		t.Fatalf("findTrafficRule: %v", err)
+	}
+	if rule != nil {
+		t.Errorf("the traffic rule of the deleted alias is left: %v", rule)
+	}
+
+	rule, err = findTrafficRule(project.ID.String(), "hello", "")
+	if err != nil {
+		t.Fatalf("findTrafficRule: %v", err)
+	}
+	if rule == nil {
+		t.Errorf("the traffic rule of the function was deleted with the alias")
+	}
+}
@@ -0,0 +1,406 @@
+package functions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"Backend/models"
+	"Backend/utils"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/gin-gonic/gin"
+)
+
+var errBuildQueueFull = errors.New("the build queue is full")
+
+// buildQueue runs the image builds of the deployed functions in the background.
+var buildQueue = newBuildQueue(
+	utils.GetEnvInt("BUILD_WORKERS", 2),
+	utils.GetEnvInt("BUILD_QUEUE_SIZE", 100),
+	utils.GetEnvDuration("BUILD_TIMEOUT", 15*time.Minute),
+)
+
+// buildJob carries everything a worker needs to produce the image of a function version.
+// image builds, loads or pulls the image and returns its ID. The image is then checked
+// with a trial container when verify is set, tagged and the function version recorded.
+// archive, when set, stores the source of the version once its image is built.
+// force allows replacing an existing version with a different content.
+// tags are the ones given to the image once the version is saved.
+// cleanup removes the files the job worked on once it is over.
+type buildJob struct {
+	build    *models.Build
+	metadata models.Function
+	log      *buildLog
+	image    func(ctx context.Context, log *buildLog) (string, error)
+	archive  func(ctx context.Context, log *buildLog) error
+	verify   bool
+	force    bool
+	tags     []string
+	cleanup  func()
+}
+
+// buildLog keeps the output of a running build in memory so that it can be
+// followed live. notify is closed and replaced whenever the log changes.
+type buildLog struct {
+	mu     sync.Mutex
+	lines  []string
+	status models.BuildStatus
+	done   bool
+	notify chan struct{}
+}
+
+func newBuildLog() *buildLog {
+	return &buildLog{
+		status: models.BuildQueued,
+		notify: make(chan struct{}),
+	}
+}
+
+func (l *buildLog) append(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines = append(l.lines, line)
+	l.changed()
+}
+
+func (l *buildLog) setStatus(status models.BuildStatus, done bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.status = status
+	l.done = done
+	l.changed()
+}
+
+// changed wakes up the followers of the log. It must be called with the lock held.
+func (l *buildLog) changed() {
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+// since returns the lines appended after the given offset, the current status
+// of the build and a channel closed on the next change.
+func (l *buildLog) since(offset int) ([]string, models.BuildStatus, bool, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lines[offset:], l.status, l.done, l.notify
+}
+
+func (l *buildLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return strings.Join(l.lines, "\n")
+}
+
+type buildManager struct {
+	jobs    chan *buildJob
+	timeout time.Duration
+	logs    sync.Map
+}
+
+func newBuildQueue(workers int, size int, timeout time.Duration) *buildManager {
+	q := &buildManager{
+		jobs:    make(chan *buildJob, size),
+		timeout: timeout,
+	}
+
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+
+	return q
+}
+
+// enqueue records a queued build for the function version of the job and hands it to the workers.
+// It returns a copy of the queued build, the build of the job being updated by the worker.
+func (q *buildManager) enqueue(job *buildJob) (*models.Build, error) {
+	build := &models.Build{
+		ProjectId:  job.metadata.ProjectId,
+		FunctionId: job.metadata.FunctionId,
+		Version:    job.metadata.Version,
+		Status:     models.BuildQueued,
+	}
+
+	if err := utils.DB.Create(build).Error; err != nil {
+		return nil, err
+	}
+
+	job.build = build
+	job.log = newBuildLog()
+
+	q.logs.Store(build.ID.String(), job.log)
+
+	queued := *build
+
+	select {
+	case q.jobs <- job:
+		return &queued, nil
+	default:
+		q.logs.Delete(build.ID.String())
+		q.finish(job, errBuildQueueFull)
+		return nil, errBuildQueueFull
+	}
+}
+
+// liveLog returns the in-memory log of a build that is still queued or running.
+func (q *buildManager) liveLog(build *models.Build) (*buildLog, bool) {
+	l, ok := q.logs.Load(build.ID.String())
+	if !ok {
+		return nil, false
+	}
+	return l.(*buildLog), true
+}
+
+func (q *buildManager) work() {
+	for job := range q.jobs {
+		q.run(job)
+	}
+}
+
+func (q *buildManager) run(job *buildJob) {
+	defer job.cleanup()
+
+	now := time.Now()
+	job.build.StartedAt = &now
+	job.build.Status = models.BuildBuilding
+	if err := utils.DB.Save(job.build).Error; err != nil {
+		utils.Logger.Errorf("cannot mark build %s as building: %v", job.build.ID, err)
+	}
+	job.log.setStatus(models.BuildBuilding, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+	defer cancel()
+
+	imageID, err := job.image(ctx, job.log)
+	if err == nil {
+		err = saveFunctionImage(ctx, job, imageID)
+	}
+	q.finish(job, err)
+}
+
+// finish persists the outcome and the logs of a build, then releases its live log.
+func (q *buildManager) finish(job *buildJob, err error) {
+	now := time.Now()
+	job.build.FinishedAt = &now
+	job.build.Status = models.BuildSucceeded
+	if err != nil {
+		job.build.Status = models.BuildFailed
+		job.build.Error = err.Error()
+		job.log.append(fmt.Sprintf("build failed: %v", err))
+
+		var failure *buildError
+		if errors.As(err, &failure) {
+			job.build.FailedStep = failure.step
+			job.build.LogExcerpt = strings.Join(failure.excerpt, "\n")
+		}
+	}
+	job.build.Logs = job.log.String()
+
+	if dbErr := utils.DB.Save(job.build).Error; dbErr != nil {
+		utils.Logger.Errorf("cannot save the outcome of build %s: %v", job.build.ID, dbErr)
+	}
+
+	job.log.setStatus(job.build.Status, true)
+	q.logs.Delete(job.build.ID.String())
+}
+
+// buildExcerptLines is the number of log lines kept in the excerpt of a failed build.
+const buildExcerptLines = 20
+
+// buildError describes an image build that the Docker daemon reported as failed.
+type buildError struct {
+	step    string
+	message string
+	excerpt []string
+}
+
+func (e *buildError) Error() string {
+	if e.step == "" {
+		return e.message
+	}
+	return fmt.Sprintf("%s: %s", e.step, e.message)
+}
+
+// writeBuildOutput decodes the JSON message stream of an image build into the build log.
+// It returns the ID of the built image, or a buildError carrying the failing step and
+// the last lines of output when the stream reports an error. The log ends with the
+// number of steps reused from the layer cache of the daemon.
+func writeBuildOutput(body io.Reader, log *buildLog) (string, error) {
+	steps, cached := 0, 0
+	imageID, err := writeJSONMessages(body, log, func(line string) {
+		if strings.HasPrefix(line, "Step ") {
+			steps++
+		}
+		if strings.Contains(line, "Using cache") {
+			cached++
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if imageID == "" {
+		return "", fmt.Errorf("the build did not produce an image")
+	}
+
+	log.append(fmt.Sprintf("layer cache: %d of %d steps reused", cached, steps))
+
+	return imageID, nil
+}
+
+// writeJSONMessages decodes a JSON message stream of the Docker daemon, as sent
+// by image builds, loads and pulls, into the build log. Every line written is
+// also handed to onLine when given. It returns the image ID sent as auxiliary
+// data, if any, or a buildError when the stream reports an error.
+func writeJSONMessages(body io.Reader, log *buildLog, onLine func(line string)) (string, error) {
+	var imageID, step string
+	var recent []string
+
+	decoder := json.NewDecoder(body)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err != io.EOF {
+				return "", err
+			}
+			return imageID, nil
+		}
+
+		var lines []string
+		switch {
+		case msg.Error != nil || msg.ErrorMessage != "":
+			message := msg.ErrorMessage
+			if msg.Error != nil {
+				message = msg.Error.Message
+			}
+			log.append(message)
+			return "", &buildError{
+				step:    step,
+				message: message,
+				excerpt: recent,
+			}
+		case msg.Aux != nil:
+			var aux struct {
+				ID string `json:"ID"`
+			}
+			if err := json.Unmarshal(*msg.Aux, &aux); err == nil && aux.ID != "" {
+				imageID = aux.ID
+			}
+		case msg.Stream != "":
+			lines = strings.Split(strings.TrimRight(msg.Stream, "\n"), "\n")
+		case msg.Status != "" && msg.ProgressMessage == "":
+			lines = []string{strings.TrimSpace(msg.ID + " " + msg.Status)}
+		}
+
+		for _, line := range lines {
+			line = strings.TrimRight(line, "\r")
+			if strings.HasPrefix(line, "Step ") {
+				step = line
+			}
+			log.append(line)
+			if onLine != nil {
+				onLine(line)
+			}
+
+			recent = append(recent, line)
+			if len(recent) > buildExcerptLines {
+				recent = recent[1:]
+			}
+		}
+	}
+}
+
+// FailInterruptedBuilds marks the builds left queued or running by a previous
+// engine process as failed, since nothing will ever pick them up again.
+func FailInterruptedBuilds() error {
+	return utils.DB.
+		Model(&models.Build{}).
+		Where("status IN ?", []models.BuildStatus{models.BuildQueued, models.BuildBuilding}).
+		Updates(map[string]interface{}{
+			"status":      models.BuildFailed,
+			"error":       "interrupted by an engine restart",
+			"finished_at": time.Now(),
+		}).
+		Error
+}
+
+// GetBuild reports the status of a function build.
+func GetBuild(c *gin.Context) {
+	build, err := utils.GetBuildFromContextParams(c)
+	if utils.HandleError(c, http.StatusNotFound, err, "cannot find the build") {
+		return
+	}
+
+	utils.JsonSuccessH(
+		c,
+		http.StatusOK,
+		fmt.Sprintf("build is %s", build.Status),
+		build,
+	)
+}
+
+// StreamBuildLogs streams the output of a build as server-sent events.
+// Running builds are followed live until they finish, finished builds have
+// their stored logs replayed. Status events are sent whenever the build status
+// changes, the last event always carries the final one.
+func StreamBuildLogs(c *gin.Context) {
+	build, err := utils.GetBuildFromContextParams(c)
+	if utils.HandleError(c, http.StatusNotFound, err, "cannot find the build") {
+		return
+	}
+
+	log, live := buildQueue.liveLog(build)
+	if !live {
+		// the build may have finished since it was read, reload its stored logs
+		err = utils.DB.First(build).Error
+		if utils.HandleError(c, http.StatusInternalServerError, err, "cannot read the build logs") {
+			return
+		}
+
+		c.Stream(func(w io.Writer) bool {
+			if build.Logs != "" {
+				for _, line := range strings.Split(build.Logs, "\n") {
+					c.SSEvent("log", line)
+				}
+			}
+			c.SSEvent("status", build.Status)
+			return false
+		})
+		return
+	}
+
+	offset := 0
+	var lastStatus models.BuildStatus
+	c.Stream(func(w io.Writer) bool {
+		lines, status, done, changed := log.since(offset)
+		for _, line := range lines {
+			c.SSEvent("log", line)
+		}
+		offset += len(lines)
+
+		if status != lastStatus {
+			c.SSEvent("status", status)
+			lastStatus = status
+		}
+
+		if done {
+			return false
+		}
+
+		select {
+		case <-changed:
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package functions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"Backend/utils"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// buildHashLabel is the image label holding the hash of the source an image was built from.
+const buildHashLabel = "stackblox.build-hash"
+
+// hashSourceTree returns the hash of an extracted function source: the paths,
+// permissions and contents of its files, walked in lexical order so that the
+// hash only changes with the source itself and not with the tarball layout.
+func hashSourceTree(extractionPath string) (string, error) {
+	hash := sha256.New()
+
+	err := filepath.WalkDir(extractionPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(extractionPath, path)
+		if err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(hash, "%s\x00%s\x00", filepath.ToSlash(relativePath), info.Mode())
+
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			_, _ = io.WriteString(hash, target)
+		case info.Mode().IsRegular():
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(hash, file)
+			_ = file.Close()
+			if err != nil {
+				return err
+			}
+		}
+
+		_, _ = hash.Write([]byte{0})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// findCachedImage returns the ID of the most recent image built from the source
+// with the given hash, or an empty string when there is none.
+func findCachedImage(ctx context.Context, buildHash string) (string, error) {
+	images, err := utils.DockerClient.ImageList(ctx, types.ImageListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", buildHashLabel, buildHash))),
+	})
+	if err != nil || len(images) == 0 {
+		return "", err
+	}
+
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Created > images[j].Created
+	})
+
+	return images[0].ID, nil
+}
+
+// reuseCachedImage reports whether the cached image is still there to be reused,
+// since it may have been removed between the deploy request and its build.
+func reuseCachedImage(ctx context.Context, imageID string, log *buildLog) bool {
+	if _, _, err := utils.DockerClient.ImageInspectWithRaw(ctx, imageID); err != nil {
+		log.append(fmt.Sprintf("the cached image %s is gone, building the source again", imageID))
+		return false
+	}
+
+	log.append(fmt.Sprintf("reusing the image %s built from the same source", imageID))
+	return true
+}
@@ -1,9 +1,9 @@
 package functions
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"Backend/models"
+	"Backend/pkg/runtimes"
 	"Backend/utils"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -23,14 +24,14 @@ import (
 	"github.com/google/uuid"
 	"github.com/iancoleman/strcase"
 	"github.com/lucsky/cuid"
+	"github.com/moby/patternmatcher/ignorefile"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 // DeployFunction manages the deployment process for a new function.
-// It handles the file upload, saves the function code, extracts the tarball,
-// reads function metadata, generates and writes the Dockerfile and entrypoint,
-// builds the Docker image, and saves the function metadata to the database.
-// On successful deployment, it sends a success response with the function metadata.
+// It handles the file upload, then queues the build of the uploaded source
+// with the Dockerfile and entrypoint generated for it.
 func DeployFunction(c *gin.Context) {
 	file, err := c.FormFile("function")
 	if utils.HandleError(c, http.StatusBadRequest, err, "function upload failed") {
@@ -42,6 +43,33 @@ func DeployFunction(c *gin.Context) {
 		return
 	}
 
+	queueSourceBuild(c, sourceBuild{
+		tarballPath: uploadedTarballPath,
+		force:       forceRequested(c),
+		prepare:     generateSourceFiles,
+	})
+}
+
+// sourceBuild describes the build of an uploaded function source.
+// An existing version is only replaced by a different source when force is set.
+// An image already built from the same source is reused unless noCache is set.
+// prepare writes the files the engine adds to the source and returns their relative paths.
+type sourceBuild struct {
+	tarballPath string
+	force       bool
+	noCache     bool
+	prepare     func(extractionPath string, metadata models.Function) ([]string, error)
+}
+
+// queueSourceBuild extracts the tarball, reads function metadata, prepares the
+// Dockerfile and entrypoint unless the function ships its own Dockerfile, then
+// queues the build of the Docker image. The image is built, the source archived
+// and the function metadata saved to the database in the background, so it
+// answers right away with the build that can be followed through the builds endpoints.
+// The build is skipped when an image was already built from the very same source.
+func queueSourceBuild(c *gin.Context, source sourceBuild) {
+	uploadedTarballPath := source.tarballPath
+
 	functionExtractionPath, err := utils.ExtractTarball(uploadedTarballPath)
 	if utils.HandleError(c, http.StatusBadRequest, err, "unable to extract the function code") {
 		cleanUpDeploy(functionExtractionPath, uploadedTarballPath)
@@ -60,71 +88,173 @@ func DeployFunction(c *gin.Context) {
 		return
 	}
 
-	err = generateAndWriteDockerfile(functionExtractionPath, functionMetadata)
-	if utils.HandleError(c, http.StatusBadRequest, err, "failed to generate/write the dockerfile") {
+	err = checkProjectCeilings(c, functionMetadata.ResourceLimits)
+	if utils.HandleError(c, http.StatusBadRequest, err, "invalid resource limits") {
 		cleanUpDeploy(functionExtractionPath, uploadedTarballPath)
 		return
 	}
 
-	err = generateAndWriteEntrypoint(functionExtractionPath, functionMetadata)
-	if utils.HandleError(c, http.StatusBadRequest, err, "failed to generate/write the entrypoint") {
+	functionMetadata.SourceHash, err = hashFile(uploadedTarballPath)
+	if utils.HandleError(c, http.StatusInternalServerError, err, "failed to hash the function source") {
 		cleanUpDeploy(functionExtractionPath, uploadedTarballPath)
 		return
 	}
 
-	err = buildAndSaveDockerImage(c, functionExtractionPath, &functionMetadata)
-	if utils.HandleError(c, http.StatusBadRequest, err, "failed to build the image") {
+	err = checkVersionImmutable(&functionMetadata, source.force)
+	if utils.HandleError(c, versionErrorStatus(err), err, "the function version cannot be deployed") {
 		cleanUpDeploy(functionExtractionPath, uploadedTarballPath)
 		return
 	}
 
-	cleanUpDeploy(functionExtractionPath, uploadedTarballPath)
+	// a Dockerfile shipped with the function is built as-is
+	var generated []string
+	if !functionMetadata.CustomDockerfile {
+		generated, err = source.prepare(functionExtractionPath, functionMetadata)
+		if utils.HandleError(c, http.StatusBadRequest, err, "failed to generate/write the dockerfile and entrypoint") {
+			cleanUpDeploy(functionExtractionPath, uploadedTarballPath)
+			return
+		}
+	}
 
+	functionMetadata.BuildHash, err = hashSourceTree(functionExtractionPath)
+	if utils.HandleError(c, http.StatusInternalServerError, err, "failed to hash the function source") {
+		cleanUpDeploy(functionExtractionPath, uploadedTarballPath)
+		return
+	}
+
+	cachedImageID := ""
+	if !source.noCache {
+		cachedImageID, err = findCachedImage(c, functionMetadata.BuildHash)
+		if err != nil {
+			utils.Logger.Warnf("cannot look up the build cache: %v", err)
+		}
+	}
+
+	job := &buildJob{
+		metadata: functionMetadata,
+		archive:  archiveJob(functionMetadata, uploadedTarballPath, functionExtractionPath, generated),
+		// generated images honor the function contract by construction, custom ones have to prove it
+		verify: functionMetadata.CustomDockerfile,
+		force:  source.force,
+		cleanup: func() {
+			cleanUpDeploy(functionExtractionPath, uploadedTarballPath)
+		},
+	}
+	job.image = func(ctx context.Context, log *buildLog) (string, error) {
+		// the build only reports being cached once the cached image is actually reused
+		if cachedImageID != "" && reuseCachedImage(ctx, cachedImageID, log) {
+			job.build.Cached = true
+			return cachedImageID, nil
+		}
+		return buildImageFromSource(ctx, functionExtractionPath, functionMetadata.BuildHash, log)
+	}
+
+	build, err := buildQueue.enqueue(job)
+	if utils.HandleError(c, http.StatusServiceUnavailable, err, "failed to queue the build") {
+		cleanUpDeploy(functionExtractionPath, uploadedTarballPath)
+		return
+	}
+
+	// cacheCandidate only tells that the build cache holds an image of the same source,
+	// whether the build managed to reuse it is reported by the build itself.
 	utils.JsonSuccessH(
 		c,
-		http.StatusOK,
-		"function deployed successfully",
+		http.StatusAccepted,
+		"function build queued",
 		gin.H{
-			"metadata": functionMetadata,
+			"build":          build,
+			"cacheCandidate": cachedImageID != "",
+			"metadata":       functionMetadata,
 		},
 	)
 }
 
 // ExecuteFunction handles the execution of a specified function.
-// It retrieves the function entity, creates and starts a container for the function,
-// polls the health check of the container, and forwards the request to the container.
+// It retrieves the function entity, resolves the requested version or alias,
+// acquires a warm container for that version from the pool, forwards the request
+// to it and hands the container back to the pool so that following invocations can reuse it.
+// The version served, and the alias it was resolved from, are reported in the response
+// headers and recorded with the outcome of the invocation.
 func ExecuteFunction(c *gin.Context) {
 	functionEntity, err := utils.GetFunctionFromContextParams(c)
 	if utils.HandleError(c, http.StatusBadRequest, err, "failed to find function") {
 		return
 	}
 
-	imageVersion := getImageVersion(c)
-
-	containerID, dynamicPort, err := createAndStartContainer(c, functionEntity, imageVersion)
-	if utils.HandleError(c, http.StatusInternalServerError, err, "failed to create/start container") {
+	functionEntity, alias, err := resolveFunctionVersion(c, functionEntity)
+	if utils.HandleError(c, http.StatusNotFound, err, "failed to resolve the function version") {
 		return
 	}
 
-	isHealthy := pollContainerHealthCheck(dynamicPort)
-	if !isHealthy {
-		utils.JsonError(
-			c,
-			http.StatusInternalServerError,
-			nil,
-			"container did not become healthy",
-		)
+	if alias != "" {
+		c.Header("X-Function-Alias", alias)
+	}
+	c.Header("X-Function-Version", functionEntity.Version)
+
+	invocationId := uuid.New()
+	c.Header("X-Invocation-Id", invocationId.String())
+
+	started := time.Now()
+	timedOut := false
+	defer func() {
+		recordInvocation(&models.Invocation{
+			ID:         invocationId,
+			ProjectId:  functionEntity.ProjectId,
+			FunctionId: functionEntity.FunctionId,
+			Version:    functionEntity.Version,
+			Alias:      alias,
+			StatusCode: c.Writer.Status(),
+			DurationMs: time.Since(started).Milliseconds(),
+			TimedOut:   timedOut,
+		})
+	}()
+
+	instance, err := containerPool.acquire(c, functionEntity, functionEntity.Version)
+	if utils.HandleError(c, acquireErrorStatus(err), err, "failed to acquire a function container") {
 		return
 	}
 
-	jsonResponse, containerResp, err := forwardRequestToContainer(c, dynamicPort)
-	if utils.HandleError(c, http.StatusInternalServerError, err, "failed to forward request to container") {
+	// The forwarded request is canceled when the client goes away as well.
+	timeout := invocationTimeout(functionEntity)
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	deadline := startInvocationDeadline(timeout, cancel)
+	defer deadline.stop()
+
+	containerResp, err := forwardRequestToContainer(ctx, c, instance.port)
+	if err != nil {
+		switch {
+		case deadline.hasExpired():
+			// The function is still busy with the request, its container cannot be reused.
+			containerPool.kill(instance)
+			timedOut = true
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{
+				"error":        fmt.Sprintf("the function did not answer within %s", timeout),
+				"help":         "function invocation timed out",
+				"invocationId": invocationId,
+			})
+		case c.Request.Context().Err() != nil:
+			containerPool.kill(instance)
+			c.AbortWithStatus(statusClientClosedRequest)
+		default:
+			containerPool.discard(instance)
+			utils.JsonError(c, http.StatusInternalServerError, err, "failed to forward request to container")
+		}
 		return
 	}
 
-	cleanupContainer(containerID)
+	// The container goes back to the pool only once the whole response, streamed or not, is sent.
+	err = sendContainerResponse(c, containerResp, deadline)
+	if err != nil {
+		// The status and headers are already sent, the client is left with a truncated body.
+		// The container may still be writing the rest of it, so it cannot be reused.
+		containerPool.kill(instance)
+		timedOut = deadline.hasExpired()
+		utils.Logger.Warnf("failed to send the response of invocation %s: %v", invocationId, err)
+		return
+	}
 
-	sendContainerResponse(c, containerResp, jsonResponse)
+	containerPool.release(instance)
 }
 
 // cleanUpDeploy removes the generated files and the uploaded tarball
@@ -139,38 +269,126 @@ func cleanUpDeploy(extractionPath string, tarballPath string) {
 	}
 }
 
-// saveUploadedFile handles the saving of uploaded files and returns the functionID and path.
+// saveUploadedFile handles the saving of uploaded files and returns the path.
 func saveUploadedFile(c *gin.Context, file *multipart.FileHeader) (string, error) {
-	functionID := uuid.NewString()
-	uploadedTarballPath := fmt.Sprintf(
+	uploadedTarballPath := newUploadPath()
+	err := c.SaveUploadedFile(file, uploadedTarballPath)
+	return uploadedTarballPath, err
+}
+
+// newUploadPath returns a new unique path in the uploads directory.
+func newUploadPath() string {
+	return fmt.Sprintf(
 		"%s/%s.tar.gz",
 		os.Getenv("UPLOADS_PATH"),
-		functionID,
+		uuid.NewString(),
 	)
-	err := c.SaveUploadedFile(file, uploadedTarballPath)
-	return uploadedTarballPath, err
 }
 
 // readFunctionMetadata reads and returns the function metadata.
 func readFunctionMetadata(functionExtractionPath string) (models.Function, error) {
-	def, lang, err := utils.ReadDefinitionFileFromExtractionPath(functionExtractionPath)
+	customDockerfile := hasCustomDockerfile(functionExtractionPath)
+
+	runtime, err := runtimes.Detect(functionExtractionPath)
+	if err != nil && !customDockerfile {
+		return models.Function{}, err
+	}
+
+	var def *models.Definition
+	var language models.Language
+	var runtimeVersion string
+
+	switch {
+	case runtime != nil:
+		def, err = runtime.ReadDefinition(functionExtractionPath)
+		language = runtime.Language()
+	case runtimes.HasManifest(functionExtractionPath):
+		def, err = runtimes.ReadManifest(functionExtractionPath)
+		language = dockerfileLanguage
+	default:
+		err = fmt.Errorf("%s is required to read the name and version of a function without a known runtime", runtimes.ManifestFile)
+	}
 	if err != nil {
 		return models.Function{}, err
 	}
+
+	// the runtime version only picks the base image of the generated Dockerfile,
+	// whose entrypoint server answers the health endpoint of the runtime
+	healthPath := runtimes.DefaultHealthEndpoint
+	if !customDockerfile {
+		runtimeVersion, err = runtimes.SelectVersion(runtime, def.RuntimeVersion)
+		if err != nil {
+			return models.Function{}, err
+		}
+		healthPath = runtime.HealthEndpoint()
+	}
+
+	minInstances, maxInstances, err := resolveInstanceBounds(def.Stackblox)
+	if err != nil {
+		return models.Function{}, err
+	}
+
+	resourceLimits, err := ParseResourceSettings(def.Stackblox.ResourceSettings)
+	if err != nil {
+		return models.Function{}, err
+	}
+
+	timeoutMs, err := resolveInvocationTimeout(def.Stackblox.Timeout)
+	if err != nil {
+		return models.Function{}, err
+	}
+
 	return models.Function{
-		Name:        def.Name,
-		Description: def.Description,
-		FunctionId:  strcase.ToKebab(def.Name),
-		Version:     def.Version,
-		Language:    lang,
-		Main:        def.Main,
+		Name:             def.Name,
+		Description:      def.Description,
+		FunctionId:       strcase.ToKebab(def.Name),
+		Version:          def.Version,
+		Language:         language,
+		Main:             def.Main,
+		RuntimeVersion:   runtimeVersion,
+		CustomDockerfile: customDockerfile,
+		HealthEndpoint:   healthPath,
+		MinInstances:     minInstances,
+		MaxInstances:     maxInstances,
+		TimeoutMs:        timeoutMs,
+		ResourceLimits:   resourceLimits,
 		//... other metadata ...
 	}, nil
 }
 
+// hasCustomDockerfile reports whether the function ships its own Dockerfile.
+func hasCustomDockerfile(functionExtractionPath string) bool {
+	info, err := os.Stat(filepath.Join(functionExtractionPath, "Dockerfile"))
+	return err == nil && !info.IsDir()
+}
+
+// generateSourceFiles generates and writes the Dockerfile, the entrypoint and the .dockerignore,
+// returning their paths relative to the function source.
+func generateSourceFiles(functionExtractionPath string, functionMetadata models.Function) ([]string, error) {
+	if err := generateAndWriteDockerfile(functionExtractionPath, functionMetadata); err != nil {
+		return nil, err
+	}
+
+	entrypointName, err := generateAndWriteEntrypoint(functionExtractionPath, functionMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	dockerignoreContent, err := runtimes.GenerateDockerignoreContent(functionMetadata, functionExtractionPath)
+	if err != nil {
+		return nil, err
+	}
+	dockerignorePath := filepath.Join(functionExtractionPath, runtimes.DockerignoreFile)
+	if err = os.WriteFile(dockerignorePath, []byte(dockerignoreContent), 0644); err != nil {
+		return nil, err
+	}
+
+	return []string{"Dockerfile", entrypointName, runtimes.DockerignoreFile}, nil
+}
+
 // generateAndWriteDockerfile generates and writes the Dockerfile.
 func generateAndWriteDockerfile(functionExtractionPath string, functionMetadata models.Function) error {
-	dockerfileContent, err := utils.GenerateDockerfileContent(functionMetadata)
+	dockerfileContent, err := runtimes.GenerateDockerfileContent(functionMetadata, functionExtractionPath)
 	if err != nil {
 		return err
 	}
@@ -178,29 +396,149 @@ func generateAndWriteDockerfile(functionExtractionPath string, functionMetadata
 	return os.WriteFile(dockerfilePath, []byte(dockerfileContent), 0644)
 }
 
-// generateAndWriteEntrypoint generates and writes the entrypoint.
-func generateAndWriteEntrypoint(functionExtractionPath string, functionMetadata models.Function) error {
-	entrypointContent, entrypointName, err := utils.GenerateEntrypointContent(functionMetadata)
+// generateAndWriteEntrypoint generates and writes the entrypoint, returning its path relative to the function source.
+func generateAndWriteEntrypoint(functionExtractionPath string, functionMetadata models.Function) (string, error) {
+	entrypointContent, entrypointName, err := runtimes.GenerateEntrypointContent(functionMetadata, functionExtractionPath)
 	if err != nil {
-		return err
+		return "", err
 	}
 	entrypointPath := filepath.Join(functionExtractionPath, entrypointName)
-	return os.WriteFile(entrypointPath, []byte(entrypointContent), 0644)
+	if err = os.MkdirAll(filepath.Dir(entrypointPath), 0755); err != nil {
+		return "", err
+	}
+	return entrypointName, os.WriteFile(entrypointPath, []byte(entrypointContent), 0644)
 }
 
-// buildAndSaveDockerImage builds and saves the Docker image.
-func buildAndSaveDockerImage(c *gin.Context, functionExtractionPath string, functionMetadata *models.Function) error {
-	tar, err := archive.TarWithOptions(functionExtractionPath, &archive.TarOptions{})
+// buildImageFromSource builds the Docker image of the extracted function source,
+// writing its output to the build log. The image is built untagged, labelled with
+// the hash of the source for the build cache, and its ID returned. As the docker
+// CLI does, the paths matching the .dockerignore are left out of the build context.
+func buildImageFromSource(ctx context.Context, functionExtractionPath string, buildHash string, log *buildLog) (string, error) {
+	excludes, err := readDockerignore(functionExtractionPath)
+	if err != nil {
+		return "", err
+	}
+
+	tar, err := archive.TarWithOptions(functionExtractionPath, &archive.TarOptions{
+		ExcludePatterns: excludes,
+	})
+	if err != nil {
+		return "", err
+	}
+	buildOpts := getDockerBuildOptions(buildHash)
+	buildResponse, err := utils.DockerClient.ImageBuild(ctx, tar, buildOpts)
 	if err != nil {
+		return "", err
+	}
+	defer buildResponse.Body.Close()
+
+	return writeBuildOutput(buildResponse.Body, log)
+}
+
+// readDockerignore returns the exclusion patterns of the .dockerignore of the
+// function source, never excluding the Dockerfile the build needs.
+func readDockerignore(functionExtractionPath string) ([]string, error) {
+	file, err := os.Open(filepath.Join(functionExtractionPath, runtimes.DockerignoreFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	excludes, err := ignorefile.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(excludes, "!Dockerfile"), nil
+}
+
+// saveFunctionImage saves the function metadata to the database, pushes the image of
+// the function version to the registry, tags it locally and archives its source.
+// Nothing is saved, pushed, tagged nor archived unless the image exists in the
+// daemon, the version is new or deployed again with the same content, and, when
+// verify is set, a trial container answered the health check.
+func saveFunctionImage(ctx context.Context, job *buildJob, imageID string) error {
+	functionMetadata := &job.metadata
+	log := job.log
+
+	inspect, _, err := utils.DockerClient.ImageInspectWithRaw(ctx, imageID)
+	if err != nil {
+		return fmt.Errorf("image %s cannot be found: %v", imageID, err)
+	}
+
+	functionMetadata.ImageDigest = inspect.ID
+	if functionMetadata.SourceHash == "" {
+		functionMetadata.SourceHash = inspect.ID
+	}
+
+	if err = checkVersionImmutable(functionMetadata, job.force); err != nil {
 		return err
 	}
-	buildOpts := getDockerBuildOptions(functionMetadata)
-	buildResponse, err := utils.DockerClient.ImageBuild(c, tar, buildOpts)
+
+	if job.verify {
+		var project models.Project
+		if err = utils.DB.First(&project, "id = ?", functionMetadata.ProjectId.String()).Error; err != nil {
+			return err
+		}
+
+		err = verifyImageContract(
+			ctx,
+			imageID,
+			healthEndpoint(functionMetadata),
+			effectiveLimits(functionMetadata, &project),
+			log,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the version is written before its image is tagged, in a transaction holding its
+	// row until then: a concurrent deploy of the version waits for it to be over,
+	// and is then checked against the content it saved
+	var previous *models.Function
+	err = utils.DB.Transaction(func(tx *gorm.DB) error {
+		previous, err = saveMetadataToDB(tx, functionMetadata, job.force)
+		if err != nil {
+			return err
+		}
+
+		newest, err := isNewestVersion(tx, functionMetadata)
+		if err != nil {
+			return err
+		}
+
+		tags := getImageTags(functionMetadata, newest)
+
+		if err := pushFunctionImage(ctx, imageID, tags, log); err != nil {
+			return fmt.Errorf("cannot push the image to the registry: %v", err)
+		}
+
+		for _, tag := range tags {
+			if err := utils.DockerClient.ImageTag(ctx, imageID, tag); err != nil {
+				return err
+			}
+		}
+		job.tags = tags
+
+		// the source is archived once the version is known to be accepted, so that a
+		// rejected deploy never replaces the archived source of the live version
+		if job.archive != nil {
+			return job.archive(ctx, log)
+		}
+
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	defer buildResponse.Body.Close()
-	return saveMetadataToDB(functionMetadata)
+
+	containerPool.drainReplaced(previous, functionMetadata)
+
+	return nil
 }
 
 // fillFunctionMetadata fills the extra metadata required for the function entity
@@ -217,51 +555,106 @@ func fillFunctionMetadata(c *gin.Context, model *models.Function) error {
 }
 
 // getDockerBuildOptions creates and returns Docker build options.
-func getDockerBuildOptions(functionMetadata *models.Function) types.ImageBuildOptions {
+func getDockerBuildOptions(buildHash string) types.ImageBuildOptions {
 	return types.ImageBuildOptions{
-		SuppressOutput: true,
+		SuppressOutput: false,
 		Remove:         true,
 		ForceRemove:    true,
-		Tags: []string{
-			fmt.Sprintf("%s:%s", functionMetadata.FunctionId, functionMetadata.Version),
-			fmt.Sprintf("%s:latest", functionMetadata.FunctionId),
+		Labels: map[string]string{
+			buildHashLabel: buildHash,
 		},
 	}
 }
 
-// saveMetadataToDB saves the function metadata to the database.
-func saveMetadataToDB(functionMetadata *models.Function) error {
-	err := utils.DB.
-		Clauses(
-			clause.OnConflict{
-				Columns: []clause.Column{
-					{Name: "function_id"},
-					{Name: "version"},
-					{Name: "project_id"},
-				},
-				DoUpdates: clause.Assignments(map[string]interface{}{
-					"updated_at": "NOW()",
-				}),
-			},
+// getImageTags returns the tags applied to the image of a function version, latest
+// included only for the newest version of the function.
+func getImageTags(functionMetadata *models.Function, newest bool) []string {
+	tags := []string{imageReference(functionMetadata, functionMetadata.Version)}
+	if newest {
+		tags = append(tags, imageReference(functionMetadata, latestVersion))
+	}
+
+	return tags
+}
+
+// isNewestVersion reports whether a saved version is the newest of its function,
+// the one latest points at. A version deployed again or rebuilt keeps its creation
+// time, so it stays behind the versions created after it.
+func isNewestVersion(tx *gorm.DB, functionMetadata *models.Function) (bool, error) {
+	var newer int64
+	err := tx.
+		Model(&models.Function{}).
+		Where(
+			"function_id = ? AND project_id = ? AND version <> ? AND created_at > ?",
+			functionMetadata.FunctionId,
+			functionMetadata.ProjectId.String(),
+			functionMetadata.Version,
+			functionMetadata.CreatedAt,
 		).
-		Create(&functionMetadata).
+		Count(&newer).
 		Error
 
-	if err != nil {
-		return err
-	}
+	return newer == 0, err
+}
 
-	return utils.DB.First(&functionMetadata).Error
+// functionUpdateColumns are the columns replaced when a version is deployed again.
+// created_at is left out so that the version keeps its place among the others.
+var functionUpdateColumns = []string{
+	"updated_at", "deleted_at", "name", "description", "runtime_version",
+	"custom_dockerfile", "min_instances", "max_instances", "health_endpoint",
+	"timeout_ms", "source_hash", "image_digest", "build_hash", "memory_bytes",
+	"cpu_shares", "pids_limit", "tmpfs_bytes", "language", "main",
 }
 
-// getImageVersion extracts the image version from the request header.
-// If not provided, defaults to "latest".
-func getImageVersion(c *gin.Context) string {
-	imageVersion := c.Request.Header.Get("x-image-version")
-	if imageVersion == "" {
-		imageVersion = "latest"
+// sameContentCondition restricts the replacement of a version to the same content,
+// as checkVersionImmutable does, unless the existing version was deleted.
+const sameContentCondition = "functions.deleted_at IS NOT NULL OR " +
+	"(functions.source_hash <> '' AND functions.source_hash = excluded.source_hash)"
+
+// saveMetadataToDB saves the function metadata to the database with tx, and returns
+// the version as it was before, nil when it is new. A version deployed again replaces
+// the metadata of the existing one only with the same content or when forced. The
+// check is part of the write, so that concurrent deploys of a new version with
+// different contents cannot both pass it.
+func saveMetadataToDB(tx *gorm.DB, functionMetadata *models.Function, force bool) (*models.Function, error) {
+	var previous *models.Function
+	err := tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(
+			"function_id = ? AND version = ? AND project_id = ?",
+			functionMetadata.FunctionId,
+			functionMetadata.Version,
+			functionMetadata.ProjectId.String(),
+		).
+		First(&previous).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		previous = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	onConflict := clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "function_id"},
+			{Name: "version"},
+			{Name: "project_id"},
+		},
+		DoUpdates: clause.AssignmentColumns(functionUpdateColumns),
+	}
+	if !force {
+		onConflict.Where = clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: sameContentCondition}}}
+	}
+
+	result := tx.Clauses(onConflict).Create(&functionMetadata)
+	if result.Error != nil {
+		return nil, result.Error
 	}
-	return imageVersion
+	if result.RowsAffected == 0 {
+		return nil, versionConflict(functionMetadata)
+	}
+
+	return previous, tx.First(&functionMetadata).Error
 }
 
 // createAndStartContainer creates and starts a Docker container for the given function entity.
@@ -354,104 +747,88 @@ func generateEnvironmentVariables(entities interface{}, prefix string) []string
 }
 
 // createContainer initializes a new Docker container with the provided configurations.
+// The image is pulled from the registry first when the local daemon does not have it.
+// The container is bounded by the resource limits of the function version.
 func createContainer(c *gin.Context, functionEntity *models.Function, imageVersion string, envs []string) (string, error) {
+	image := imageReference(functionEntity, imageVersion)
+	if err := ensureLocalImage(c, image); err != nil {
+		return "", err
+	}
+
+	hostConfig := &container.HostConfig{
+		PortBindings: nat.PortMap{
+			"8080": []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: ""}},
+		},
+		NetworkMode: container.NetworkMode(functionEntity.Project.NetworkName),
+	}
+	applyResourceLimits(hostConfig, effectiveLimits(functionEntity, &functionEntity.Project))
+
 	resp, err := utils.DockerClient.ContainerCreate(
 		c,
 		&container.Config{
-			Image: fmt.Sprintf("%s:%s", functionEntity.FunctionId, imageVersion),
+			Image: image,
 			ExposedPorts: nat.PortSet{
 				"8080": {},
 			},
 			Env: envs,
 		},
-		&container.HostConfig{
-			PortBindings: nat.PortMap{
-				"8080": []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: ""}},
-			},
-			NetworkMode: container.NetworkMode(functionEntity.Project.NetworkName),
-		},
+		hostConfig,
 		nil,
 		nil,
-		fmt.Sprintf(
-			"func_%s",
-			cuid.New(),
-		),
+		functionContainerPrefix+cuid.New(),
 	)
 	return resp.ID, err
 }
 
 // fetchContainerDynamicPort retrieves the dynamic port of the specified Docker container.
-func fetchContainerDynamicPort(c *gin.Context, containerID string) (string, error) {
-	inspect, err := utils.DockerClient.ContainerInspect(c, containerID)
+func fetchContainerDynamicPort(ctx context.Context, containerID string) (string, error) {
+	inspect, err := utils.DockerClient.ContainerInspect(ctx, containerID)
 	if err != nil {
 		return "", err
 	}
-	return inspect.NetworkSettings.Ports["8080/tcp"][0].HostPort, nil
+	bindings := inspect.NetworkSettings.Ports["8080/tcp"]
+	if len(bindings) == 0 {
+		return "", fmt.Errorf("container %s does not publish port 8080", containerID)
+	}
+	return bindings[0].HostPort, nil
+}
+
+// healthEndpoint returns the path the containers of a function answer 200 on once ready.
+func healthEndpoint(functionEntity *models.Function) string {
+	if functionEntity.HealthEndpoint == "" {
+		return runtimes.DefaultHealthEndpoint
+	}
+	return functionEntity.HealthEndpoint
 }
 
-// pollContainerHealthCheck checks the health status of the container by polling its health check endpoint.
+// pollContainerHealthCheck checks the health status of the container by polling the given health check endpoint.
 // It returns true if the container is healthy, otherwise false.
-func pollContainerHealthCheck(dynamicPort string) bool {
+func pollContainerHealthCheck(dynamicPort string, healthEndpoint string) bool {
 	retryIntervals := []time.Duration{
 		250 * time.Millisecond,
 		500 * time.Millisecond,
 		1 * time.Second,
 		3 * time.Second,
 	}
-	healthCheckURL := fmt.Sprintf("http://localhost:%s/health", dynamicPort)
+	healthCheckURL := fmt.Sprintf("http://localhost:%s%s", dynamicPort, healthEndpoint)
 
 	client := &http.Client{}
 	for _, interval := range retryIntervals {
 		time.Sleep(interval) // Wait for the specified interval before checking health.
 		resp, err := client.Get(healthCheckURL)
-		if err == nil && resp.StatusCode == http.StatusOK {
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
 			return true
 		}
 	}
 	return false
 }
 
-// forwardRequestToContainer sends the client's request to the container and retrieves its response.
-// It returns the JSON response, the HTTP response, and any error that occurs.
-func forwardRequestToContainer(c *gin.Context, dynamicPort string) (interface{}, *http.Response, error) {
-	containerURL := fmt.Sprintf("http://localhost:%s", dynamicPort)
-	containerReq, err := http.NewRequest(c.Request.Method, containerURL, c.Request.Body)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	for k, v := range c.Request.Header {
-		containerReq.Header.Set(k, strings.Join(v, ","))
-	}
-
-	client := &http.Client{}
-	containerResp, err := client.Do(containerReq)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	containerResponseBody, err := io.ReadAll(containerResp.Body)
-	if err != nil {
-		return nil, nil, err
-	}
-	containerResp.Body.Close()
-
-	var jsonResponse interface{}
-	if len(containerResponseBody) > 0 {
-		err = json.Unmarshal(containerResponseBody, &jsonResponse)
-		if err != nil {
-			utils.Logger.Warnf("failed to unmarshal container response: %s", err)
-			jsonResponse = string(containerResponseBody)
-		}
-	} else {
-		jsonResponse = ""
-	}
-
-	return jsonResponse, containerResp, nil
-}
-
 // cleanupContainer stops and removes the specified Docker container.
-// This is done asynchronously to not delay the response.
+// This is done asynchronously to not delay the response or the pool eviction.
 func cleanupContainer(containerID string) {
 	go func() {
 		errMessage, err := utils.StopAndRemoveContainer(containerID)
@@ -460,16 +837,3 @@ func cleanupContainer(containerID string) {
 		}
 	}()
 }
-
-// sendContainerResponse sends the container's response back to the client.
-// It sets the necessary headers and JSON response.
-func sendContainerResponse(c *gin.Context, containerResp *http.Response, jsonResponse interface{}) {
-	for k, v := range containerResp.Header {
-		c.Header(k, strings.Join(v, ","))
-	}
-
-	c.JSON(
-		containerResp.StatusCode,
-		jsonResponse,
-	)
-}
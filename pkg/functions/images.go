@@ -0,0 +1,593 @@
+package functions
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"Backend/models"
+	"Backend/pkg/runtimes"
+	"Backend/utils"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/gin-gonic/gin"
+	"github.com/iancoleman/strcase"
+)
+
+const (
+	// prebuiltLanguage is the language of the functions deployed from a prebuilt image.
+	prebuiltLanguage models.Language = "image"
+	// dockerfileLanguage is the language of the functions built from their own
+	// Dockerfile without any runtime recognizing their source.
+	dockerfileLanguage models.Language = "dockerfile"
+)
+
+// DeployFunctionImage registers a new function version from a prebuilt image,
+// either uploaded as a `docker save` or OCI archive in the image field, or
+// referenced in the bundled registry with the reference field. The image is
+// loaded or pulled in the background, checked against the function contract
+// and tagged under the function name and version without running any build.
+func DeployFunctionImage(c *gin.Context) {
+	var deployImageRequest models.DeployImageDTO
+
+	err := c.ShouldBind(&deployImageRequest)
+	if utils.HandleError(c, http.StatusBadRequest, err, "cannot parse input") {
+		return
+	}
+
+	file, fileErr := c.FormFile("image")
+	hasArchive := fileErr == nil
+	if hasArchive == (deployImageRequest.Reference != "") {
+		utils.JsonError(
+			c,
+			http.StatusBadRequest,
+			fmt.Errorf("either an image archive or a registry reference is required"),
+			"upload the image archive or reference the image, not both",
+		)
+		return
+	}
+
+	if !hasArchive {
+		err = checkRegistryReference(deployImageRequest.Reference)
+		if utils.HandleError(c, http.StatusBadRequest, err, "invalid image reference") {
+			return
+		}
+	}
+
+	minInstances, maxInstances, err := resolveInstanceBounds(models.DefinitionSettings{
+		MinInstances: deployImageRequest.MinInstances,
+		MaxInstances: deployImageRequest.MaxInstances,
+	})
+	if utils.HandleError(c, http.StatusBadRequest, err, "invalid scaling settings") {
+		return
+	}
+
+	timeoutMs, err := resolveInvocationTimeout(deployImageRequest.Timeout)
+	if utils.HandleError(c, http.StatusBadRequest, err, "invalid timeout") {
+		return
+	}
+
+	resourceLimits, err := ParseResourceSettings(deployImageRequest.ResourceSettings)
+	if err == nil {
+		err = checkProjectCeilings(c, resourceLimits)
+	}
+	if utils.HandleError(c, http.StatusBadRequest, err, "invalid resource limits") {
+		return
+	}
+
+	functionMetadata := models.Function{
+		Name:           deployImageRequest.Name,
+		Description:    deployImageRequest.Description,
+		FunctionId:     strcase.ToKebab(deployImageRequest.Name),
+		Version:        deployImageRequest.Version,
+		Language:       prebuiltLanguage,
+		HealthEndpoint: runtimes.DefaultHealthEndpoint,
+		MinInstances:   minInstances,
+		MaxInstances:   maxInstances,
+		TimeoutMs:      timeoutMs,
+		ResourceLimits: resourceLimits,
+	}
+
+	err = fillFunctionMetadata(c, &functionMetadata)
+	if utils.HandleError(c, http.StatusInternalServerError, err, "failed to fill function metadata") {
+		return
+	}
+
+	job := &buildJob{
+		metadata: functionMetadata,
+		verify:   true,
+		force:    deployImageRequest.Force,
+		cleanup:  func() {},
+	}
+
+	if hasArchive {
+		archivePath, err := saveUploadedFile(c, file)
+		if utils.HandleError(c, http.StatusBadRequest, err, "unable to save the uploaded image") {
+			return
+		}
+
+		job.metadata.SourceHash, err = hashFile(archivePath)
+		if err == nil {
+			err = checkVersionImmutable(&job.metadata, job.force)
+		}
+		if utils.HandleError(c, versionErrorStatus(err), err, "the function version cannot be deployed") {
+			_ = os.Remove(archivePath)
+			return
+		}
+
+		// the job cleanup runs on the worker once the image is tagged for the function
+		var loadedTags []string
+		job.image = func(ctx context.Context, log *buildLog) (string, error) {
+			imageID, tags, err := loadImageArchive(ctx, archivePath, log)
+			loadedTags = tags
+			return imageID, err
+		}
+		job.cleanup = func() {
+			removeLoadedTags(context.Background(), loadedTags, job.tags)
+			if err := os.Remove(archivePath); err != nil {
+				utils.Logger.Warnf("cannot remove image archive %s", archivePath)
+			}
+		}
+	} else {
+		job.image = func(ctx context.Context, log *buildLog) (string, error) {
+			return pullImage(ctx, deployImageRequest.Reference, log)
+		}
+	}
+
+	build, err := buildQueue.enqueue(job)
+	if utils.HandleError(c, http.StatusServiceUnavailable, err, "failed to queue the image deploy") {
+		job.cleanup()
+		return
+	}
+
+	utils.JsonSuccessH(
+		c,
+		http.StatusAccepted,
+		"function image deploy queued",
+		gin.H{
+			"build":    build,
+			"metadata": functionMetadata,
+		},
+	)
+}
+
+// checkRegistryReference makes sure an image reference points into the bundled registry.
+func checkRegistryReference(reference string) error {
+	registry := utils.RegistryAddress()
+	if registry == "" {
+		return fmt.Errorf("no image registry is configured")
+	}
+
+	if !strings.HasPrefix(reference, registry+"/") {
+		return fmt.Errorf("the reference %s does not point into the registry %s", reference, registry)
+	}
+
+	return nil
+}
+
+// archiveManifest is an image of the manifest.json of a `docker save` archive.
+type archiveManifest struct {
+	Config   string
+	RepoTags []string
+}
+
+// archiveIndex is the index.json of an OCI archive, naming its images in annotations.
+type archiveIndex struct {
+	Manifests []struct {
+		Annotations map[string]string `json:"annotations"`
+	} `json:"manifests"`
+}
+
+// ociImageNameAnnotation names an image of an OCI archive, the tag it is loaded under.
+const ociImageNameAnnotation = "io.containerd.image.name"
+
+// loadImageArchive loads a `docker save` or OCI archive holding a single image,
+// possibly under several tags. It returns the ID of that image and the tags the
+// archive created in the daemon, to be removed once the image is tagged for the function.
+func loadImageArchive(ctx context.Context, archivePath string, log *buildLog) (string, []string, error) {
+	archiveTags, err := readArchiveTags(archivePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot read the image archive: %v", err)
+	}
+
+	loadedTags, err := checkArchiveTags(archiveTags, func(tag string) (string, error) {
+		return localImageID(ctx, tag)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	imageArchive, err := os.Open(archivePath)
+	if err != nil {
+		return "", nil, err
+	}
+	defer imageArchive.Close()
+
+	loadResponse, err := utils.DockerClient.ImageLoad(ctx, imageArchive, true)
+	if err != nil {
+		return "", nil, err
+	}
+	defer loadResponse.Body.Close()
+
+	var loaded []string
+	_, err = writeJSONMessages(loadResponse.Body, log, func(line string) {
+		if strings.HasPrefix(line, "Loaded image: ") {
+			loaded = append(loaded, strings.TrimPrefix(line, "Loaded image: "))
+		} else if strings.HasPrefix(line, "Loaded image ID: ") {
+			loaded = append(loaded, strings.TrimPrefix(line, "Loaded image ID: "))
+		}
+	})
+	if err != nil {
+		return "", loadedTags, err
+	}
+
+	// the tags of a same image are reported one by one
+	imageIDs := map[string]bool{}
+	for _, image := range loaded {
+		inspect, _, err := utils.DockerClient.ImageInspectWithRaw(ctx, image)
+		if err != nil {
+			return "", loadedTags, err
+		}
+		imageIDs[inspect.ID] = true
+	}
+
+	if len(imageIDs) != 1 {
+		return "", loadedTags, fmt.Errorf("the archive must hold exactly one image, it holds %d", len(imageIDs))
+	}
+
+	imageID, err := exposedImageID(ctx, loaded[0])
+	return imageID, loadedTags, err
+}
+
+// readArchiveTags returns the tags an image archive carries along with the ID of
+// their image, read from its manifest.json or, for the OCI archives lacking one,
+// from the image names of its index.json, which do not tell the image ID.
+func readArchiveTags(archivePath string) (map[string]string, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stream, err := archive.DecompressStream(file)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var manifest []archiveManifest
+	var index archiveIndex
+	hasManifest := false
+
+	reader := tar.NewReader(stream)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch path.Clean(header.Name) {
+		case "manifest.json":
+			hasManifest = true
+			err = json.NewDecoder(reader).Decode(&manifest)
+		case "index.json":
+			err = json.NewDecoder(reader).Decode(&index)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", header.Name, err)
+		}
+	}
+
+	tags := map[string]string{}
+	if hasManifest {
+		for _, image := range manifest {
+			imageID := "sha256:" + strings.TrimSuffix(path.Base(image.Config), ".json")
+			for _, tag := range image.RepoTags {
+				tags[tag] = imageID
+			}
+		}
+		return tags, nil
+	}
+
+	for _, manifest := range index.Manifests {
+		if name := manifest.Annotations[ociImageNameAnnotation]; name != "" {
+			tags[name] = ""
+		}
+	}
+
+	return tags, nil
+}
+
+// checkArchiveTags refuses the image archives carrying a tag that already names
+// another image in the daemon, since loading them would move the tag, be it the one
+// of a base image or of another function, to the uploaded image. localImageID returns
+// the ID of the image a tag names, empty when there is none. checkArchiveTags
+// returns the tags the archive creates in the daemon.
+func checkArchiveTags(archiveTags map[string]string, localImageID func(tag string) (string, error)) ([]string, error) {
+	tags := make([]string, 0, len(archiveTags))
+	for tag := range archiveTags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	var created []string
+	for _, tag := range tags {
+		localID, err := localImageID(tag)
+		if err != nil {
+			return nil, err
+		}
+
+		if localID == "" {
+			created = append(created, tag)
+			continue
+		}
+		if localID != archiveTags[tag] {
+			return nil, fmt.Errorf("the archive tag %s already names another image, retag the image before saving it", tag)
+		}
+	}
+
+	return created, nil
+}
+
+// localImageID returns the ID of the given image in the daemon, empty when it is missing.
+func localImageID(ctx context.Context, image string) (string, error) {
+	inspect, _, err := utils.DockerClient.ImageInspectWithRaw(ctx, image)
+	if client.IsErrNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return inspect.ID, nil
+}
+
+// removeLoadedTags removes the tags an image archive created in the daemon, except
+// the ones of the function. The image itself stays as long as the function tags it.
+func removeLoadedTags(ctx context.Context, loadedTags []string, functionTags []string) {
+	for _, tag := range loadedTags {
+		if slices.Contains(functionTags, tag) {
+			continue
+		}
+
+		_, err := utils.DockerClient.ImageRemove(ctx, tag, types.ImageRemoveOptions{})
+		if err != nil && !client.IsErrNotFound(err) {
+			utils.Logger.Warnf("cannot remove the tag %s of the loaded image archive: %v", tag, err)
+		}
+	}
+}
+
+// pullImage pulls the referenced image and returns its ID. The credentials of the
+// bundled registry are sent along when the reference points into it.
+func pullImage(ctx context.Context, reference string, log *buildLog) (string, error) {
+	options := types.ImagePullOptions{}
+	if checkRegistryReference(reference) == nil {
+		options.RegistryAuth = utils.RegistryAuth()
+	}
+
+	pullResponse, err := utils.DockerClient.ImagePull(ctx, reference, options)
+	if err != nil {
+		return "", err
+	}
+	defer pullResponse.Close()
+
+	if _, err = writeJSONMessages(pullResponse, log, nil); err != nil {
+		return "", err
+	}
+
+	return exposedImageID(ctx, reference)
+}
+
+// exposedImageID returns the ID of the given image, refusing the images not exposing port 8080.
+func exposedImageID(ctx context.Context, image string) (string, error) {
+	inspect, _, err := utils.DockerClient.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return "", err
+	}
+
+	if inspect.Config == nil {
+		return "", fmt.Errorf("the image %s does not expose port 8080", image)
+	}
+	if _, ok := inspect.Config.ExposedPorts["8080/tcp"]; !ok {
+		return "", fmt.Errorf("the image %s does not expose port 8080", image)
+	}
+
+	return inspect.ID, nil
+}
+
+// pushFunctionImage pushes the image under each of the given tags to the bundled
+// registry, so that engines missing the image locally can pull it. It does nothing
+// when no registry is configured.
+func pushFunctionImage(ctx context.Context, imageID string, tags []string, log *buildLog) error {
+	if utils.RegistryAddress() == "" {
+		return nil
+	}
+
+	for _, tag := range tags {
+		reference := utils.RegistryReference(tag)
+		log.append(fmt.Sprintf("pushing %s", reference))
+
+		if err := utils.DockerClient.ImageTag(ctx, imageID, reference); err != nil {
+			return err
+		}
+
+		err := pushImage(ctx, reference, log)
+
+		// the registry reference is only needed for the push itself
+		_, removeErr := utils.DockerClient.ImageRemove(ctx, reference, types.ImageRemoveOptions{})
+		if removeErr != nil {
+			utils.Logger.Warnf("cannot untag %s: %v", reference, removeErr)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func pushImage(ctx context.Context, reference string, log *buildLog) error {
+	pushResponse, err := utils.DockerClient.ImagePush(ctx, reference, types.ImagePushOptions{
+		RegistryAuth: utils.RegistryAuth(),
+	})
+	if err != nil {
+		return err
+	}
+	defer pushResponse.Close()
+
+	_, err = writeJSONMessages(pushResponse, log, nil)
+	return err
+}
+
+// ensureLocalImage makes sure the given image exists in the local daemon. A
+// missing image is pulled from the bundled registry and tagged under its local name.
+func ensureLocalImage(ctx context.Context, image string) error {
+	_, _, err := utils.DockerClient.ImageInspectWithRaw(ctx, image)
+	if err == nil || !client.IsErrNotFound(err) || utils.RegistryAddress() == "" {
+		return err
+	}
+
+	reference := utils.RegistryReference(image)
+	utils.Logger.Infof("image %s is missing locally, pulling %s", image, reference)
+
+	pullResponse, err := utils.DockerClient.ImagePull(ctx, reference, types.ImagePullOptions{
+		RegistryAuth: utils.RegistryAuth(),
+	})
+	if err != nil {
+		return err
+	}
+	defer pullResponse.Close()
+
+	if err = jsonmessage.DisplayJSONMessagesStream(pullResponse, io.Discard, 0, false, nil); err != nil {
+		return err
+	}
+
+	if err = utils.DockerClient.ImageTag(ctx, reference, image); err != nil {
+		return err
+	}
+
+	// the image is usable under its local name, a leftover registry tag does not fail the invocation
+	if _, err = utils.DockerClient.ImageRemove(ctx, reference, types.ImageRemoveOptions{}); err != nil {
+		utils.Logger.Warnf("cannot remove the registry tag %s of the pulled image: %v", reference, err)
+	}
+
+	return nil
+}
+
+// ImageName returns the repository of the images of a function. It is namespaced
+// by the project ID, which unlike the project name never changes, so that the
+// functions of different projects sharing a name never share their tags.
+func ImageName(function *models.Function) string {
+	return fmt.Sprintf("%s/%s", function.ProjectId, function.FunctionId)
+}
+
+// imageReference returns the reference of the image of a function tagged with the given version.
+func imageReference(function *models.Function, version string) string {
+	return fmt.Sprintf("%s:%s", ImageName(function), version)
+}
+
+// RemoveFunctionImages removes every tag of the images of a function. Only the
+// tags of the function repository are removed, an image also tagged for another
+// function keeps its other tags.
+func RemoveFunctionImages(ctx context.Context, function *models.Function) error {
+	images, err := utils.DockerClient.ImageList(ctx, types.ImageListOptions{
+		Filters: filters.NewArgs(filters.Arg("reference", ImageName(function))),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, image := range images {
+		for _, tag := range image.RepoTags {
+			if !strings.HasPrefix(tag, ImageName(function)+":") {
+				continue
+			}
+
+			_, err = utils.DockerClient.ImageRemove(ctx, tag, types.ImageRemoveOptions{
+				Force:         true,
+				PruneChildren: true,
+			})
+			if err != nil && !client.IsErrNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// MigrateLegacyImageTags retags the images built before the image names were
+// namespaced by project, from `<function>:<version>` to the name given by ImageName.
+// A legacy tag claimed by functions of several projects cannot be attributed to
+// any of them, so it is left in place and those functions must be deployed again.
+func MigrateLegacyImageTags() error {
+	var functions []models.Function
+	if err := utils.DB.Find(&functions).Error; err != nil {
+		return err
+	}
+
+	owners := map[string]map[string]bool{}
+	for _, function := range functions {
+		if owners[function.FunctionId] == nil {
+			owners[function.FunctionId] = map[string]bool{}
+		}
+		owners[function.FunctionId][function.ProjectId.String()] = true
+	}
+
+	ctx := context.Background()
+	migrated := map[string]bool{}
+
+	for i := range functions {
+		function := &functions[i]
+
+		for _, version := range []string{function.Version, "latest"} {
+			legacy := fmt.Sprintf("%s:%s", function.FunctionId, version)
+			if migrated[legacy] {
+				continue
+			}
+
+			_, _, err := utils.DockerClient.ImageInspectWithRaw(ctx, legacy)
+			if client.IsErrNotFound(err) {
+				continue
+			}
+			if err != nil {
+				utils.Logger.Warnf("cannot inspect the legacy image %s: %v", legacy, err)
+				continue
+			}
+
+			if len(owners[function.FunctionId]) > 1 {
+				utils.Logger.Warnf("the legacy image %s is claimed by several projects, deploy them again", legacy)
+				continue
+			}
+
+			if err = utils.DockerClient.ImageTag(ctx, legacy, imageReference(function, version)); err != nil {
+				utils.Logger.Warnf("cannot retag the legacy image %s: %v", legacy, err)
+				continue
+			}
+
+			if _, err = utils.DockerClient.ImageRemove(ctx, legacy, types.ImageRemoveOptions{}); err != nil {
+				utils.Logger.Warnf("cannot untag the legacy image %s: %v", legacy, err)
+			}
+
+			migrated[legacy] = true
+			utils.Logger.Infof("migrated the legacy image %s to %s", legacy, imageReference(function, version))
+		}
+	}
+
+	return nil
+}
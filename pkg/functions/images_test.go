@@ -0,0 +1,160 @@
+package functions
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTestArchive writes a tarball holding the given files, gzipped when compress is set.
+func writeTestArchive(t *testing.T, files map[string]string, compress bool) string {
+	t.Helper()
+
+	archivePath := filepath.Join(t.TempDir(), "image.tar")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("create archive: %v", err)
+	}
+	defer file.Close()
+
+	var out io.Writer = file
+	if compress {
+		gzipWriter := gzip.NewWriter(file)
+		defer gzipWriter.Close()
+		out = gzipWriter
+	}
+
+	tarWriter := tar.NewWriter(out)
+	defer tarWriter.Close()
+
+	for name, content := range files {
+		err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))})
+		if err == nil {
+			_, err = tarWriter.Write([]byte(content))
+		}
+		if err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	return archivePath
+}
+
+func TestReadArchiveTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		compress bool
+		want     map[string]string
+	}{
+		{
+			name: "docker save",
+			files: map[string]string{
+				"manifest.json": `[{"Config":"abc.json","RepoTags":["app:1","app:latest"],"Layers":[]}]`,
+				"abc.json":      `{}`,
+			},
+			want: map[string]string{"app:1": "sha256:abc", "app:latest": "sha256:abc"},
+		},
+		{
+			name: "gzipped docker save with OCI blobs",
+			files: map[string]string{
+				"manifest.json": `[{"Config":"blobs/sha256/def","RepoTags":["node:18-alpine"],"Layers":[]}]`,
+				"index.json":    `{"manifests":[{"annotations":{"io.containerd.image.name":"docker.io/library/node:18-alpine"}}]}`,
+			},
+			compress: true,
+			want:     map[string]string{"node:18-alpine": "sha256:def"},
+		},
+		{
+			name: "OCI archive",
+			files: map[string]string{
+				"index.json": `{"manifests":[{"annotations":{"io.containerd.image.name":"docker.io/library/app:1"}}]}`,
+			},
+			want: map[string]string{"docker.io/library/app:1": ""},
+		},
+		{
+			name: "untagged image",
+			files: map[string]string{
+				"manifest.json": `[{"Config":"abc.json","RepoTags":null,"Layers":[]}]`,
+			},
+			want: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readArchiveTags(writeTestArchive(t, tt.files, tt.compress))
+			if err != nil {
+				t.Fatalf("readArchiveTags: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckArchiveTags(t *testing.T) {
+	local := map[string]string{
+		"node:18-alpine": "sha256:base",
+		"0b7c/hello:1.0": "sha256:other",
+		"app:1":          "sha256:app",
+	}
+	localImageID := func(tag string) (string, error) {
+		return local[tag], nil
+	}
+
+	tests := []struct {
+		name        string
+		archiveTags map[string]string
+		wantCreated []string
+		wantErr     bool
+	}{
+		{
+			name:        "new tags",
+			archiveTags: map[string]string{"app:2": "sha256:app", "app:latest": "sha256:app"},
+			wantCreated: []string{"app:2", "app:latest"},
+		},
+		{
+			name:        "tag of the same image",
+			archiveTags: map[string]string{"app:1": "sha256:app", "app:2": "sha256:app"},
+			wantCreated: []string{"app:2"},
+		},
+		{
+			name:        "base image tag",
+			archiveTags: map[string]string{"node:18-alpine": "sha256:upload"},
+			wantErr:     true,
+		},
+		{
+			name:        "tag of another function",
+			archiveTags: map[string]string{"app:2": "sha256:upload", "0b7c/hello:1.0": "sha256:upload"},
+			wantErr:     true,
+		},
+		{
+			name:        "existing tag of an OCI archive",
+			archiveTags: map[string]string{"app:1": ""},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created, err := checkArchiveTags(tt.archiveTags, localImageID)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got the tags %v, want an error", created)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkArchiveTags: %v", err)
+			}
+			if !reflect.DeepEqual(created, tt.wantCreated) {
+				t.Errorf("got %v, want %v", created, tt.wantCreated)
+			}
+		})
+	}
+}
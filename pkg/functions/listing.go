@@ -0,0 +1,385 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"Backend/models"
+	"Backend/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// FunctionStatus summarizes the state of the latest deploy of a function.
+type FunctionStatus string
+
+const (
+	// FunctionReady functions serve their latest deployed version.
+	FunctionReady FunctionStatus = "ready"
+	// FunctionDeploying functions have a build queued or running.
+	FunctionDeploying FunctionStatus = "deploying"
+	// FunctionFailed functions failed their latest build, they keep serving their previous versions if any.
+	FunctionFailed FunctionStatus = "failed"
+)
+
+// functionSummary describes a function across all its versions.
+type functionSummary struct {
+	FunctionId    string          `json:"functionId"`
+	Name          string          `json:"name"`
+	Description   string          `json:"description"`
+	Language      models.Language `json:"language"`
+	LatestVersion string          `json:"latestVersion"`
+	Versions      int             `json:"versions"`
+	Status        FunctionStatus  `json:"status"`
+	LastBuild     *models.Build   `json:"lastBuild"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
+
+// versionAggregate aggregates the versions of a function.
+type versionAggregate struct {
+	FunctionId string
+	Versions   int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// summaryFunctionId is the ID of a function in the listing query, which joins the
+// latest version of the functions with their latest build.
+const summaryFunctionId = "COALESCE(f.function_id, b.function_id)"
+
+// likeEscaper escapes the wildcards of the q search of ListFunctions.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// ListFunctions lists the functions of the project, one entry per function with
+// its latest version, a page at a time. The functions can be filtered by language,
+// status and by a q search on their ID, name and description.
+func ListFunctions(c *gin.Context) {
+	project, _ := utils.GetProjectFromContext(c)
+
+	page, pageSize, err := utils.GetPagination(c)
+	if utils.HandleError(c, http.StatusBadRequest, err, "invalid pagination") {
+		return
+	}
+
+	latest := utils.DB.
+		Model(&models.Function{}).
+		Select("DISTINCT ON (function_id) *").
+		Where("project_id = ?", project.ID.String()).
+		Order("function_id, created_at desc")
+	builds := utils.DB.
+		Model(&models.Build{}).
+		Select("DISTINCT ON (function_id) function_id, status").
+		Where("project_id = ?", project.ID.String()).
+		Order("function_id, created_at desc")
+
+	// The functions whose first version is still being built are listed as well.
+	query := utils.DB.
+		Table("(?) AS f", latest).
+		Joins("FULL JOIN (?) AS b ON b.function_id = f.function_id", builds).
+		Where("f.function_id IS NOT NULL OR b.status IN ?", []models.BuildStatus{models.BuildQueued, models.BuildBuilding})
+
+	if language := c.Query("language"); language != "" {
+		query = query.Where("f.language = ?", language)
+	}
+
+	// The status is the one buildStatus gives to the latest build.
+	switch FunctionStatus(c.Query("status")) {
+	case "":
+	case FunctionReady:
+		query = query.Where(
+			"b.status IS NULL OR b.status NOT IN ?",
+			[]models.BuildStatus{models.BuildQueued, models.BuildBuilding, models.BuildFailed},
+		)
+	case FunctionDeploying:
+		query = query.Where("b.status IN ?", []models.BuildStatus{models.BuildQueued, models.BuildBuilding})
+	case FunctionFailed:
+		query = query.Where("b.status = ?", models.BuildFailed)
+	default:
+		utils.JsonError(c, http.StatusBadRequest, fmt.Errorf("unknown status %q", c.Query("status")), "status must be ready, deploying or failed")
+		return
+	}
+
+	if q := c.Query("q"); q != "" {
+		pattern := "%" + likeEscaper.Replace(q) + "%"
+		query = query.Where(
+			summaryFunctionId+" ILIKE ? OR f.name ILIKE ? OR f.description ILIKE ?",
+			pattern, pattern, pattern,
+		)
+	}
+
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	err = query.Count(&total).Error
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot list the functions") {
+		return
+	}
+
+	var functionIds []string
+	err = query.
+		Order(summaryFunctionId).
+		Limit(pageSize).
+		Offset((page-1)*pageSize).
+		Pluck(summaryFunctionId, &functionIds).
+		Error
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot list the functions") {
+		return
+	}
+
+	summaries, err := summarizeFunctions(project.ID.String(), functionIds)
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot list the functions") {
+		return
+	}
+
+	utils.JsonSuccessH(
+		c,
+		http.StatusOK,
+		fmt.Sprintf("%d functions found", total),
+		gin.H{
+			"functions": summaries,
+			"page":      page,
+			"pageSize":  pageSize,
+			"total":     total,
+		},
+	)
+}
+
+// GetFunction describes a function: its summary, the settings of its latest
+// version, its versions a page at a time, its aliases and traffic rules, and
+// the time it was last invoked.
+func GetFunction(c *gin.Context) {
+	project, _ := utils.GetProjectFromContext(c)
+	functionId := c.Param("functionId")
+
+	page, pageSize, err := utils.GetPagination(c)
+	if utils.HandleError(c, http.StatusBadRequest, err, "invalid pagination") {
+		return
+	}
+
+	latest, err := latestVersions(project.ID.String(), []string{functionId})
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot find the function") {
+		return
+	}
+	if len(latest) == 0 {
+		utils.JsonError(c, http.StatusNotFound, fmt.Errorf("record not found"), "failed to find function")
+		return
+	}
+
+	stats, err := versionStatistics(project.ID.String(), []string{functionId})
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot find the function") {
+		return
+	}
+
+	versions, totalVersions, aliases, err := listFunctionVersions(project.ID, functionId, page, pageSize)
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot list the function versions") {
+		return
+	}
+
+	lastBuild, err := latestBuilds(project.ID.String(), functionId)
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot find the function builds") {
+		return
+	}
+
+	var rules []models.TrafficRule
+	err = utils.DB.
+		Where("function_id = ? AND project_id = ?", functionId, project.ID.String()).
+		Order("alias").
+		Find(&rules).
+		Error
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot list the traffic rules") {
+		return
+	}
+
+	var lastInvocationAt *time.Time
+	var lastInvocation models.Invocation
+	err = utils.DB.
+		Where("function_id = ? AND project_id = ?", functionId, project.ID.String()).
+		Order("created_at desc").
+		First(&lastInvocation).
+		Error
+	if err == nil {
+		lastInvocationAt = &lastInvocation.CreatedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.JsonError(c, http.StatusInternalServerError, err, "cannot find the last invocation")
+		return
+	}
+
+	summary := summarize(latest[0], stats[functionId], lastBuild[functionId])
+
+	utils.JsonSuccessH(
+		c,
+		http.StatusOK,
+		"function found",
+		gin.H{
+			"function":         summary,
+			"resources":        functionResources(latest[0], project),
+			"versions":         versions,
+			"page":             page,
+			"pageSize":         pageSize,
+			"totalVersions":    totalVersions,
+			"aliases":          aliases,
+			"trafficRules":     rules,
+			"lastInvocationAt": lastInvocationAt,
+		},
+	)
+}
+
+// functionResources returns the resource settings of a function version: the
+// declared limits, and the ones applied to its containers.
+func functionResources(function models.Function, project *models.Project) gin.H {
+	return gin.H{
+		"runtimeVersion": function.RuntimeVersion,
+		"minInstances":   function.MinInstances,
+		"maxInstances":   function.MaxInstances,
+		"timeout":        invocationTimeout(&function).String(),
+		"limits":         function.ResourceLimits,
+		"appliedLimits":  effectiveLimits(&function, project),
+	}
+}
+
+// summarizeFunctions returns the summaries of the given functions of a project, in
+// the given order. The functions whose first version is still being built are included.
+func summarizeFunctions(projectId string, functionIds []string) ([]functionSummary, error) {
+	summaries := []functionSummary{}
+	if len(functionIds) == 0 {
+		return summaries, nil
+	}
+
+	latest, err := latestVersions(projectId, functionIds)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := versionStatistics(projectId, functionIds)
+	if err != nil {
+		return nil, err
+	}
+
+	builds, err := latestBuilds(projectId, functionIds...)
+	if err != nil {
+		return nil, err
+	}
+
+	deployed := map[string]models.Function{}
+	for _, function := range latest {
+		deployed[function.FunctionId] = function
+	}
+
+	for _, functionId := range functionIds {
+		build := builds[functionId]
+		if function, ok := deployed[functionId]; ok {
+			summaries = append(summaries, summarize(function, stats[functionId], build))
+			continue
+		}
+		if build == nil {
+			continue
+		}
+		summaries = append(summaries, functionSummary{
+			FunctionId:    functionId,
+			Name:          functionId,
+			LatestVersion: build.Version,
+			Status:        FunctionDeploying,
+			LastBuild:     build,
+			CreatedAt:     build.CreatedAt,
+			UpdatedAt:     build.UpdatedAt,
+		})
+	}
+
+	return summaries, nil
+}
+
+// summarize builds the summary of a function from its latest version, the statistics
+// of all its versions, and its latest build.
+func summarize(latest models.Function, stats versionAggregate, lastBuild *models.Build) functionSummary {
+	return functionSummary{
+		FunctionId:    latest.FunctionId,
+		Name:          latest.Name,
+		Description:   latest.Description,
+		Language:      latest.Language,
+		LatestVersion: latest.Version,
+		Versions:      stats.Versions,
+		Status:        buildStatus(lastBuild),
+		LastBuild:     lastBuild,
+		CreatedAt:     stats.CreatedAt,
+		UpdatedAt:     stats.UpdatedAt,
+	}
+}
+
+// latestVersions returns the latest version of each of the given functions of a project.
+func latestVersions(projectId string, functionIds []string) ([]models.Function, error) {
+	var latest []models.Function
+	err := utils.DB.
+		Select("DISTINCT ON (function_id) *").
+		Where("project_id = ? AND function_id IN ?", projectId, functionIds).
+		Order("function_id, created_at desc").
+		Find(&latest).
+		Error
+
+	return latest, err
+}
+
+// versionStatistics returns the statistics of the versions of each of the given functions of a project.
+func versionStatistics(projectId string, functionIds []string) (map[string]versionAggregate, error) {
+	var rows []versionAggregate
+	err := utils.DB.
+		Model(&models.Function{}).
+		Select("function_id, COUNT(*) AS versions, MIN(created_at) AS created_at, MAX(updated_at) AS updated_at").
+		Where("project_id = ? AND function_id IN ?", projectId, functionIds).
+		Group("function_id").
+		Scan(&rows).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]versionAggregate{}
+	for _, row := range rows {
+		stats[row.FunctionId] = row
+	}
+
+	return stats, nil
+}
+
+// buildStatus returns the status of a function given its latest build. The
+// functions deployed before the builds were recorded have none and are ready.
+func buildStatus(build *models.Build) FunctionStatus {
+	if build == nil {
+		return FunctionReady
+	}
+
+	switch build.Status {
+	case models.BuildQueued, models.BuildBuilding:
+		return FunctionDeploying
+	case models.BuildFailed:
+		return FunctionFailed
+	default:
+		return FunctionReady
+	}
+}
+
+// latestBuilds returns the latest build of each function of a project, or of the given functions only.
+func latestBuilds(projectId string, functionIds ...string) (map[string]*models.Build, error) {
+	query := utils.DB.
+		Select("DISTINCT ON (function_id) *").
+		Where("project_id = ?", projectId).
+		Order("function_id, created_at desc")
+	if len(functionIds) > 0 {
+		query = query.Where("function_id IN ?", functionIds)
+	}
+
+	var builds []models.Build
+	if err := query.Find(&builds).Error; err != nil {
+		return nil, err
+	}
+
+	latest := map[string]*models.Build{}
+	for i := range builds {
+		latest[builds[i].FunctionId] = &builds[i]
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,49 @@
+package functions
+
+import (
+	"os"
+	"testing"
+
+	"Backend/models"
+	"Backend/utils"
+	_ "Backend/utils/testenv"
+	"github.com/google/uuid"
+)
+
+// TestMain migrates the database the tests run against, the one of the .env file.
+func TestMain(m *testing.M) {
+	err := utils.DB.AutoMigrate(
+		&models.Project{},
+		&models.Function{},
+		&models.Database{},
+		&models.Build{},
+		&models.Alias{},
+		&models.TrafficRule{},
+		&models.Invocation{},
+	)
+	if err != nil {
+		utils.Logger.Fatalf("failed to run the databases migrations: %v", err)
+	}
+
+	os.Exit(m.Run())
+}
+
+// newTestProject records a project whose functions and rows are deleted once the test is over.
+func newTestProject(t *testing.T) *models.Project {
+	t.Helper()
+
+	name := "test-" + uuid.NewString()
+	project := &models.Project{Name: name, NetworkName: name}
+	if err := utils.DB.Create(project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+
+	t.Cleanup(func() {
+		for _, model := range []interface{}{&models.Alias{}, &models.TrafficRule{}, &models.Function{}} {
+			utils.DB.Unscoped().Where("project_id = ?", project.ID.String()).Delete(model)
+		}
+		utils.DB.Unscoped().Delete(project)
+	})
+
+	return project
+}
@@ -0,0 +1,404 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"Backend/models"
+	"Backend/utils"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errQueueFull    = errors.New("too many requests are waiting for the function")
+	errQueueTimeout = errors.New("no function container became available in time")
+)
+
+// containerPool keeps the warm containers of every function image version.
+var containerPool = newPoolManager(
+	utils.GetEnvDuration("FUNCTION_IDLE_TIMEOUT", 5*time.Minute),
+	utils.GetEnvInt("FUNCTION_DEFAULT_MAX_INSTANCES", 5),
+	utils.GetEnvInt("FUNCTION_QUEUE_SIZE", 50),
+	utils.GetEnvDuration("FUNCTION_QUEUE_TIMEOUT", 10*time.Second),
+)
+
+// functionContainerPrefix starts the names of the function containers.
+const functionContainerPrefix = "func_"
+
+// platformMaxInstances is the highest maxInstances a function is allowed to declare.
+var platformMaxInstances = utils.GetEnvInt("FUNCTION_MAX_INSTANCES", 20)
+
+// poolKey identifies the pool serving one image version of a function.
+type poolKey struct {
+	projectId    string
+	functionId   string
+	imageVersion string
+}
+
+// warmContainer is a healthy function container that can serve invocations.
+// generation is the one of its pool when the container was started.
+type warmContainer struct {
+	id         string
+	port       string
+	key        poolKey
+	generation int
+	lastUsed   time.Time
+}
+
+// functionPool holds the containers of a single image version.
+// instances counts every container of the pool, busy or starting ones included.
+// A waiter receives either a released container or nil, meaning that a
+// container slot was handed over to it and that it has to start one itself.
+// Draining a pool, as its version is deleted or its image replaced, starts a new
+// generation: the containers of the previous ones are removed instead of being
+// put back once their invocation is done.
+type functionPool struct {
+	minInstances int
+	maxInstances int
+	instances    int
+	idle         []*warmContainer
+	waiters      []chan *warmContainer
+	generation   int
+}
+
+// poolManager reuses function containers across invocations. It scales a pool
+// out up to its maxInstances, queues the requests exceeding that capacity and
+// scales back down to minInstances once containers stay idle for idleTimeout.
+// startContainer and removeContainer are the Docker operations of the pools.
+type poolManager struct {
+	mu                  sync.Mutex
+	pools               map[poolKey]*functionPool
+	idleTimeout         time.Duration
+	defaultMaxInstances int
+	queueSize           int
+	queueTimeout        time.Duration
+
+	startContainer  func(c *gin.Context, functionEntity *models.Function, imageVersion string) (string, string, error)
+	removeContainer func(containerID string)
+}
+
+func newPoolManager(idleTimeout time.Duration, defaultMaxInstances int, queueSize int, queueTimeout time.Duration) *poolManager {
+	m := &poolManager{
+		pools:               map[poolKey]*functionPool{},
+		idleTimeout:         idleTimeout,
+		defaultMaxInstances: defaultMaxInstances,
+		queueSize:           queueSize,
+		queueTimeout:        queueTimeout,
+		startContainer:      startHealthyContainer,
+		removeContainer:     cleanupContainer,
+	}
+
+	go m.evictIdleLoop()
+
+	return m
+}
+
+// resolveInstanceBounds validates the scaling settings of a definition and
+// returns the min and max instances to store on the function.
+func resolveInstanceBounds(settings models.DefinitionSettings) (int, int, error) {
+	minInstances, maxInstances := settings.MinInstances, settings.MaxInstances
+
+	if minInstances < 0 || maxInstances < 0 {
+		return 0, 0, fmt.Errorf("minInstances and maxInstances cannot be negative")
+	}
+
+	if maxInstances == 0 {
+		maxInstances = containerPool.defaultMaxInstances
+		if minInstances > maxInstances {
+			maxInstances = minInstances
+		}
+	}
+
+	if minInstances > maxInstances {
+		return 0, 0, fmt.Errorf("minInstances (%d) cannot exceed maxInstances (%d)", minInstances, maxInstances)
+	}
+
+	if maxInstances > platformMaxInstances {
+		return 0, 0, fmt.Errorf("maxInstances (%d) exceeds the platform limit of %d", maxInstances, platformMaxInstances)
+	}
+
+	return minInstances, maxInstances, nil
+}
+
+// acquireErrorStatus maps an acquire error to the HTTP status returned to the client.
+func acquireErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errQueueFull):
+		return http.StatusTooManyRequests
+	case errors.Is(err, errQueueTimeout):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// acquire hands out an idle container of the requested image version. When
+// none is idle, it starts a new one if the pool is below its maxInstances,
+// otherwise it waits in the pool queue for a container to be released.
+func (m *poolManager) acquire(c *gin.Context, functionEntity *models.Function, imageVersion string) (*warmContainer, error) {
+	key := poolKey{
+		projectId:    functionEntity.ProjectId.String(),
+		functionId:   functionEntity.FunctionId,
+		imageVersion: imageVersion,
+	}
+
+	m.mu.Lock()
+	pool := m.poolFor(key, functionEntity)
+
+	if len(pool.idle) > 0 {
+		instance := pool.idle[len(pool.idle)-1]
+		pool.idle = pool.idle[:len(pool.idle)-1]
+		m.mu.Unlock()
+		return instance, nil
+	}
+
+	if pool.instances < pool.maxInstances {
+		pool.instances++
+		m.mu.Unlock()
+		return m.start(c, functionEntity, imageVersion, key)
+	}
+
+	if len(pool.waiters) >= m.queueSize {
+		m.mu.Unlock()
+		return nil, errQueueFull
+	}
+
+	waiter := make(chan *warmContainer, 1)
+	pool.waiters = append(pool.waiters, waiter)
+	m.mu.Unlock()
+
+	timer := time.NewTimer(m.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case instance := <-waiter:
+		if instance == nil {
+			return m.start(c, functionEntity, imageVersion, key)
+		}
+		return instance, nil
+	case <-timer.C:
+		return nil, m.abandon(key, waiter, errQueueTimeout)
+	case <-c.Request.Context().Done():
+		return nil, m.abandon(key, waiter, c.Request.Context().Err())
+	}
+}
+
+// poolFor returns the pool of the given key, creating it when needed.
+// It must be called with the lock held.
+func (m *poolManager) poolFor(key poolKey, functionEntity *models.Function) *functionPool {
+	pool, ok := m.pools[key]
+	if !ok {
+		pool = &functionPool{}
+		m.pools[key] = pool
+	}
+
+	pool.minInstances = functionEntity.MinInstances
+	pool.maxInstances = functionEntity.MaxInstances
+	if pool.maxInstances == 0 {
+		pool.maxInstances = m.defaultMaxInstances
+	}
+
+	return pool
+}
+
+// start creates a container in a slot already reserved in the pool.
+func (m *poolManager) start(c *gin.Context, functionEntity *models.Function, imageVersion string, key poolKey) (*warmContainer, error) {
+	m.mu.Lock()
+	generation := m.pools[key].generation
+	m.mu.Unlock()
+
+	containerID, dynamicPort, err := m.startContainer(c, functionEntity, imageVersion)
+	if err != nil {
+		m.freeSlot(key)
+		return nil, err
+	}
+
+	return &warmContainer{
+		id:         containerID,
+		port:       dynamicPort,
+		key:        key,
+		generation: generation,
+	}, nil
+}
+
+// startHealthyContainer creates a container of a function image version and waits
+// for it to answer its health check. It returns the container ID and its port.
+func startHealthyContainer(c *gin.Context, functionEntity *models.Function, imageVersion string) (string, string, error) {
+	containerID, dynamicPort, err := createAndStartContainer(c, functionEntity, imageVersion)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !pollContainerHealthCheck(dynamicPort, healthEndpoint(functionEntity)) {
+		cleanupContainer(containerID)
+		return "", "", fmt.Errorf("container %s did not become healthy", containerID)
+	}
+
+	return containerID, dynamicPort, nil
+}
+
+// abandon removes a waiter from the queue. If a container or a slot was
+// handed to the waiter in the meantime, it is given back to the pool.
+func (m *poolManager) abandon(key poolKey, waiter chan *warmContainer, reason error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pool := m.pools[key]
+	for i, w := range pool.waiters {
+		if w == waiter {
+			pool.waiters = append(pool.waiters[:i], pool.waiters[i+1:]...)
+			return reason
+		}
+	}
+
+	if instance := <-waiter; instance != nil {
+		m.releaseLocked(pool, instance)
+	} else {
+		m.freeSlotLocked(pool)
+	}
+
+	return reason
+}
+
+// release puts a container back in its pool once the invocation is done,
+// handing it straight to the first queued request if there is one.
+func (m *poolManager) release(instance *warmContainer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.releaseLocked(m.pools[instance.key], instance)
+}
+
+func (m *poolManager) releaseLocked(pool *functionPool, instance *warmContainer) {
+	if instance.generation != pool.generation {
+		m.removeContainer(instance.id)
+		m.freeSlotLocked(pool)
+		return
+	}
+
+	instance.lastUsed = time.Now()
+
+	if len(pool.waiters) > 0 {
+		waiter := pool.waiters[0]
+		pool.waiters = pool.waiters[1:]
+		waiter <- instance
+		return
+	}
+
+	pool.idle = append(pool.idle, instance)
+}
+
+// discard removes a container that cannot be trusted to serve requests anymore.
+func (m *poolManager) discard(instance *warmContainer) {
+	m.removeContainer(instance.id)
+	m.freeSlot(instance.key)
+}
+
+// kill removes a container stuck on an invocation, without waiting for it to stop gracefully.
+func (m *poolManager) kill(instance *warmContainer) {
+	go func() {
+		if err := utils.KillAndRemoveContainer(instance.id); err != nil {
+			utils.Logger.Errorf("failed to kill the container %s: %v", instance.id, err)
+		}
+	}()
+	m.freeSlot(instance.key)
+}
+
+func (m *poolManager) freeSlot(key poolKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.freeSlotLocked(m.pools[key])
+}
+
+// freeSlotLocked gives the slot of a removed container to the first queued
+// request, or shrinks the pool when nobody is waiting.
+func (m *poolManager) freeSlotLocked(pool *functionPool) {
+	if len(pool.waiters) > 0 {
+		waiter := pool.waiters[0]
+		pool.waiters = pool.waiters[1:]
+		waiter <- nil
+		return
+	}
+
+	pool.instances--
+}
+
+// drain removes the idle containers of a function, or of one of its versions when
+// imageVersion is set, and starts a new generation of its pools so that the busy
+// containers are removed as well once their invocation is done.
+func (m *poolManager) drain(projectId string, functionId string, imageVersion string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, pool := range m.pools {
+		if key.projectId != projectId || key.functionId != functionId {
+			continue
+		}
+		if imageVersion != "" && key.imageVersion != imageVersion {
+			continue
+		}
+
+		for _, instance := range pool.idle {
+			m.removeContainer(instance.id)
+			pool.instances--
+		}
+		pool.idle = nil
+		pool.generation++
+
+		if pool.instances == 0 && len(pool.waiters) == 0 {
+			delete(m.pools, key)
+		}
+	}
+}
+
+// drainReplaced drains the pool of a version deployed again with another image, so
+// that its warm containers stop serving the previous one. previous is the version
+// as it was before being saved, nil when the version is new.
+func (m *poolManager) drainReplaced(previous *models.Function, saved *models.Function) {
+	if previous == nil || previous.ImageDigest == saved.ImageDigest {
+		return
+	}
+
+	m.drain(saved.ProjectId.String(), saved.FunctionId, saved.Version)
+}
+
+// evictIdleLoop periodically removes the containers idle for too long.
+func (m *poolManager) evictIdleLoop() {
+	ticker := time.NewTicker(m.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.evictIdle()
+	}
+}
+
+// evictIdle scales every pool back down to its minInstances, removing the
+// containers that stayed idle for longer than idleTimeout first.
+func (m *poolManager) evictIdle() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deadline := time.Now().Add(-m.idleTimeout)
+
+	for key, pool := range m.pools {
+		var kept []*warmContainer
+		for _, instance := range pool.idle {
+			if pool.instances > pool.minInstances && instance.lastUsed.Before(deadline) {
+				utils.Logger.Debugf("evicting idle container %s of %s:%s", instance.id, key.functionId, key.imageVersion)
+				m.removeContainer(instance.id)
+				pool.instances--
+				continue
+			}
+			kept = append(kept, instance)
+		}
+		pool.idle = kept
+
+		if pool.instances == 0 && len(pool.waiters) == 0 {
+			delete(m.pools, key)
+		}
+	}
+}
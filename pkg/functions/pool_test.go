@@ -0,0 +1,476 @@
+package functions
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"Backend/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// fakeContainers stands for the Docker daemon of a pool under test.
+type fakeContainers struct {
+	mu      sync.Mutex
+	started []string
+	removed []string
+}
+
+func (f *fakeContainers) start(*gin.Context, *models.Function, string) (string, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := fmt.Sprintf("container-%d", len(f.started)+1)
+	f.started = append(f.started, id)
+	return id, "8080", nil
+}
+
+func (f *fakeContainers) remove(containerID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.removed = append(f.removed, containerID)
+}
+
+func (f *fakeContainers) counts() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.started), len(f.removed)
+}
+
+// newTestPoolManager returns a pool manager whose containers are faked, and
+// which does not evict the idle containers on its own.
+func newTestPoolManager(queueSize int, queueTimeout time.Duration) (*poolManager, *fakeContainers) {
+	containers := &fakeContainers{}
+	m := &poolManager{
+		pools:               map[poolKey]*functionPool{},
+		idleTimeout:         time.Minute,
+		defaultMaxInstances: 5,
+		queueSize:           queueSize,
+		queueTimeout:        queueTimeout,
+		startContainer:      containers.start,
+		removeContainer:     containers.remove,
+	}
+
+	return m, containers
+}
+
+func newTestContext() *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	return c
+}
+
+func newTestFunction(minInstances int, maxInstances int) *models.Function {
+	return &models.Function{
+		ProjectId:    uuid.New(),
+		FunctionId:   "hello",
+		MinInstances: minInstances,
+		MaxInstances: maxInstances,
+	}
+}
+
+// waitForWaiters blocks until the pool of the key has the given number of queued requests.
+func waitForWaiters(t *testing.T, m *poolManager, key poolKey, count int) {
+	t.Helper()
+
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		m.mu.Lock()
+		pool, ok := m.pools[key]
+		queued := ok && len(pool.waiters) == count
+		m.mu.Unlock()
+
+		if queued {
+			return
+		}
+	}
+	t.Fatalf("the pool never had %d queued requests", count)
+}
+
+func TestPoolReusesContainersPerImageVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		versions   []string
+		wantStarts int
+	}{
+		{"same version", []string{"1.0.0", "1.0.0", "1.0.0"}, 1},
+		{"different versions", []string{"1.0.0", "2.0.0"}, 2},
+		{"alternating versions", []string{"1.0.0", "2.0.0", "1.0.0", "2.0.0"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, containers := newTestPoolManager(10, time.Second)
+			function := newTestFunction(0, 2)
+
+			served := map[string]string{}
+			for _, version := range tt.versions {
+				instance, err := m.acquire(newTestContext(), function, version)
+				if err != nil {
+					t.Fatalf("acquire %s: %v", version, err)
+				}
+				if previous, ok := served[version]; ok && previous != instance.id {
+					t.Errorf("version %s served by %s, then by %s", version, previous, instance.id)
+				}
+				if instance.key.imageVersion != version {
+					t.Errorf("acquired a container of %s for %s", instance.key.imageVersion, version)
+				}
+				served[version] = instance.id
+				m.release(instance)
+			}
+
+			if started, _ := containers.counts(); started != tt.wantStarts {
+				t.Errorf("started %d containers, want %d", started, tt.wantStarts)
+			}
+		})
+	}
+}
+
+func TestPoolEvictsIdleContainers(t *testing.T) {
+	tests := []struct {
+		name          string
+		minInstances  int
+		idle          int
+		stale         int
+		wantInstances int
+	}{
+		{"all stale", 0, 3, 3, 0},
+		{"keeps min instances", 1, 3, 3, 1},
+		{"keeps recently used", 0, 3, 1, 2},
+		{"nothing stale", 0, 2, 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, containers := newTestPoolManager(10, time.Second)
+			function := newTestFunction(tt.minInstances, 5)
+
+			var instances []*warmContainer
+			for i := 0; i < tt.idle; i++ {
+				instance, err := m.acquire(newTestContext(), function, "1.0.0")
+				if err != nil {
+					t.Fatalf("acquire: %v", err)
+				}
+				instances = append(instances, instance)
+			}
+			for _, instance := range instances {
+				m.release(instance)
+			}
+
+			m.mu.Lock()
+			for _, instance := range instances[:tt.stale] {
+				instance.lastUsed = time.Now().Add(-2 * m.idleTimeout)
+			}
+			m.mu.Unlock()
+
+			m.evictIdle()
+
+			m.mu.Lock()
+			instanceCount := 0
+			if pool, ok := m.pools[instances[0].key]; ok {
+				instanceCount = pool.instances
+				if len(pool.idle) != pool.instances {
+					t.Errorf("%d idle containers for %d instances", len(pool.idle), pool.instances)
+				}
+			}
+			m.mu.Unlock()
+
+			if instanceCount != tt.wantInstances {
+				t.Errorf("pool has %d instances, want %d", instanceCount, tt.wantInstances)
+			}
+			if _, removed := containers.counts(); removed != tt.idle-tt.wantInstances {
+				t.Errorf("removed %d containers, want %d", removed, tt.idle-tt.wantInstances)
+			}
+		})
+	}
+}
+
+func TestPoolDrainHandsSlotToWaiter(t *testing.T) {
+	m, containers := newTestPoolManager(10, 5*time.Second)
+	function := newTestFunction(0, 1)
+
+	busy, err := m.acquire(newTestContext(), function, "1.0.0")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	type result struct {
+		instance *warmContainer
+		err      error
+	}
+	waited := make(chan result, 1)
+	go func() {
+		instance, err := m.acquire(newTestContext(), function, "1.0.0")
+		waited <- result{instance, err}
+	}()
+	waitForWaiters(t, m, busy.key, 1)
+
+	m.drain(busy.key.projectId, busy.key.functionId, "1.0.0")
+	m.release(busy)
+
+	select {
+	case r := <-waited:
+		if r.err != nil {
+			t.Fatalf("queued acquire: %v", r.err)
+		}
+		if r.instance.id == busy.id {
+			t.Errorf("the queued request got the container of the drained version")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("the queued request never got the freed slot")
+	}
+
+	started, removed := containers.counts()
+	if started != 2 || removed != 1 {
+		t.Errorf("started %d and removed %d containers, want 2 and 1", started, removed)
+	}
+}
+
+func TestPoolDrainRetiresBusyContainers(t *testing.T) {
+	m, containers := newTestPoolManager(10, 5*time.Second)
+	function := newTestFunction(0, 2)
+
+	stale, err := m.acquire(newTestContext(), function, "1.0.0")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	m.drain(stale.key.projectId, stale.key.functionId, "1.0.0")
+
+	fresh, err := m.acquire(newTestContext(), function, "1.0.0")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if fresh.id == stale.id {
+		t.Fatalf("the drained container was handed out again")
+	}
+
+	m.release(stale)
+	m.release(fresh)
+
+	reused, err := m.acquire(newTestContext(), function, "1.0.0")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if reused.id != fresh.id {
+		t.Errorf("got container %s, want the one started after the drain %s", reused.id, fresh.id)
+	}
+
+	if started, removed := containers.counts(); started != 2 || removed != 1 {
+		t.Errorf("started %d and removed %d containers, want 2 and 1", started, removed)
+	}
+}
+
+func TestPoolDrainReplaced(t *testing.T) {
+	tests := []struct {
+		name        string
+		previous    *models.Function
+		savedImage  string
+		wantRemoved int
+	}{
+		{name: "new version", previous: nil, savedImage: "sha256:b", wantRemoved: 0},
+		{name: "same image", previous: &models.Function{ImageDigest: "sha256:a"}, savedImage: "sha256:a", wantRemoved: 0},
+		{name: "replaced image", previous: &models.Function{ImageDigest: "sha256:a"}, savedImage: "sha256:b", wantRemoved: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, containers := newTestPoolManager(10, 5*time.Second)
+			function := newTestFunction(0, 1)
+			function.Version = "1.0.0"
+
+			instance, err := m.acquire(newTestContext(), function, function.Version)
+			if err != nil {
+				t.Fatalf("acquire: %v", err)
+			}
+			m.release(instance)
+
+			saved := *function
+			saved.ImageDigest = tt.savedImage
+			m.drainReplaced(tt.previous, &saved)
+
+			if _, removed := containers.counts(); removed != tt.wantRemoved {
+				t.Errorf("removed %d containers, want %d", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestPoolConcurrentInvocations(t *testing.T) {
+	const maxInstances = 3
+
+	m, containers := newTestPoolManager(100, 5*time.Second)
+	function := newTestFunction(0, maxInstances)
+
+	var mu sync.Mutex
+	busy, maxBusy := map[string]bool{}, 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			instance, err := m.acquire(newTestContext(), function, "1.0.0")
+			if err != nil {
+				t.Errorf("acquire: %v", err)
+				return
+			}
+
+			mu.Lock()
+			if busy[instance.id] {
+				t.Errorf("container %s handed out twice", instance.id)
+			}
+			busy[instance.id] = true
+			if len(busy) > maxBusy {
+				maxBusy = len(busy)
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			delete(busy, instance.id)
+			mu.Unlock()
+
+			m.release(instance)
+		}()
+	}
+	wg.Wait()
+
+	if started, _ := containers.counts(); started > maxInstances {
+		t.Errorf("started %d containers, want at most %d", started, maxInstances)
+	}
+	if maxBusy > maxInstances {
+		t.Errorf("%d containers busy at once, want at most %d", maxBusy, maxInstances)
+	}
+}
+
+func TestResolveInstanceBounds(t *testing.T) {
+	defaultMax := containerPool.defaultMaxInstances
+
+	tests := []struct {
+		name     string
+		settings models.DefinitionSettings
+		wantMin  int
+		wantMax  int
+		wantErr  bool
+	}{
+		{"defaults", models.DefinitionSettings{}, 0, defaultMax, false},
+		{"explicit bounds", models.DefinitionSettings{MinInstances: 1, MaxInstances: 3}, 1, 3, false},
+		{"min above the default max", models.DefinitionSettings{MinInstances: defaultMax + 1}, defaultMax + 1, defaultMax + 1, false},
+		{"negative min", models.DefinitionSettings{MinInstances: -1}, 0, 0, true},
+		{"negative max", models.DefinitionSettings{MaxInstances: -1}, 0, 0, true},
+		{"min above max", models.DefinitionSettings{MinInstances: 3, MaxInstances: 2}, 0, 0, true},
+		{"max above the platform limit", models.DefinitionSettings{MaxInstances: platformMaxInstances + 1}, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minInstances, maxInstances, err := resolveInstanceBounds(tt.settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if minInstances != tt.wantMin || maxInstances != tt.wantMax {
+				t.Errorf("got bounds %d-%d, want %d-%d", minInstances, maxInstances, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestAcquireErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"queue full", errQueueFull, http.StatusTooManyRequests},
+		{"queue timeout", errQueueTimeout, http.StatusServiceUnavailable},
+		{"wrapped queue full", fmt.Errorf("acquire: %w", errQueueFull), http.StatusTooManyRequests},
+		{"container failure", fmt.Errorf("container did not become healthy"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acquireErrorStatus(tt.err); got != tt.want {
+				t.Errorf("got status %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolQueueOverflow(t *testing.T) {
+	m, _ := newTestPoolManager(1, 5*time.Second)
+	function := newTestFunction(0, 1)
+
+	busy, err := m.acquire(newTestContext(), function, "1.0.0")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	queued := make(chan error, 1)
+	go func() {
+		instance, err := m.acquire(newTestContext(), function, "1.0.0")
+		if err == nil {
+			m.release(instance)
+		}
+		queued <- err
+	}()
+	waitForWaiters(t, m, busy.key, 1)
+
+	_, err = m.acquire(newTestContext(), function, "1.0.0")
+	if err != errQueueFull {
+		t.Fatalf("got error %v, want %v", err, errQueueFull)
+	}
+	if status := acquireErrorStatus(err); status != http.StatusTooManyRequests {
+		t.Errorf("got status %d, want %d", status, http.StatusTooManyRequests)
+	}
+
+	m.release(busy)
+	if err := <-queued; err != nil {
+		t.Errorf("queued acquire: %v", err)
+	}
+}
+
+func TestPoolQueueTimeout(t *testing.T) {
+	m, containers := newTestPoolManager(10, 20*time.Millisecond)
+	function := newTestFunction(0, 1)
+
+	busy, err := m.acquire(newTestContext(), function, "1.0.0")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	_, err = m.acquire(newTestContext(), function, "1.0.0")
+	if err != errQueueTimeout {
+		t.Fatalf("got error %v, want %v", err, errQueueTimeout)
+	}
+	if status := acquireErrorStatus(err); status != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want %d", status, http.StatusServiceUnavailable)
+	}
+
+	m.mu.Lock()
+	waiters := len(m.pools[busy.key].waiters)
+	m.mu.Unlock()
+	if waiters != 0 {
+		t.Errorf("the timed out request is still queued")
+	}
+
+	// The container released after the timeout goes back to the pool.
+	m.release(busy)
+	instance, err := m.acquire(newTestContext(), function, "1.0.0")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if instance.id != busy.id {
+		t.Errorf("got container %s, want the released %s", instance.id, busy.id)
+	}
+	if started, _ := containers.counts(); started != 1 {
+		t.Errorf("started %d containers, want 1", started)
+	}
+}
@@ -0,0 +1,190 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventStreamMediaType is the content type of the server-sent events.
+const eventStreamMediaType = "text/event-stream"
+
+// streamBufferSize is the most a streamed chunk is read at once before being flushed.
+const streamBufferSize = 32 * 1024
+
+// hopByHopHeaders only apply to a single connection and are not forwarded
+// between the client and the function containers.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// containerClient sends the invocations to the function containers. It leaves the
+// redirects and the content encoding of the responses to the client, so that they
+// come back exactly as the function wrote them.
+var containerClient = &http.Client{
+	Transport: func() http.RoundTripper {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DisableCompression = true
+		return transport
+	}(),
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// forwardRequestToContainer sends the client's request to the container and returns its response,
+// whose body is left for the caller to read and close.
+// The path following the function in the execution route and the query string are
+// forwarded, while the X-Forwarded headers tell the function the URL it was called at.
+// The request, reading of the response included, is bounded by ctx.
+func forwardRequestToContainer(ctx context.Context, c *gin.Context, dynamicPort string) (*http.Response, error) {
+	prefix, path, rawPath := splitFunctionPath(c)
+	containerURL := url.URL{
+		Scheme:   "http",
+		Host:     fmt.Sprintf("localhost:%s", dynamicPort),
+		Path:     path,
+		RawPath:  rawPath,
+		RawQuery: c.Request.URL.RawQuery,
+	}
+
+	containerReq, err := http.NewRequestWithContext(ctx, c.Request.Method, containerURL.String(), c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	copyHeaders(containerReq.Header, c.Request.Header)
+	containerReq.ContentLength = c.Request.ContentLength
+	setForwardedHeaders(containerReq.Header, c, prefix)
+
+	return containerClient.Do(containerReq)
+}
+
+// splitFunctionPath splits the path of an execution request into the prefix
+// routing it to the function and the path forwarded to the function, along with
+// the escaped form of the latter when the client escaped it differently.
+func splitFunctionPath(c *gin.Context) (string, string, string) {
+	path := c.Param("path")
+	if path == "" {
+		path = "/"
+	}
+
+	prefix := strings.TrimSuffix(strings.TrimSuffix(c.Request.URL.Path, c.Param("path")), "/")
+
+	rawPath := ""
+	if c.Request.URL.RawPath != "" && strings.HasPrefix(c.Request.URL.RawPath, prefix) {
+		rawPath = strings.TrimPrefix(c.Request.URL.RawPath, prefix)
+	}
+
+	return prefix, path, rawPath
+}
+
+// setForwardedHeaders sets the headers describing the URL the client called. The
+// host and protocol already forwarded by a proxy in front of the engine are kept.
+func setForwardedHeaders(header http.Header, c *gin.Context, prefix string) {
+	header.Set("X-Forwarded-Prefix", prefix)
+
+	if header.Get("X-Forwarded-Host") == "" {
+		header.Set("X-Forwarded-Host", c.Request.Host)
+	}
+
+	if header.Get("X-Forwarded-Proto") == "" {
+		proto := "http"
+		if c.Request.TLS != nil {
+			proto = "https"
+		}
+		header.Set("X-Forwarded-Proto", proto)
+	}
+
+	if clientIP, _, err := net.SplitHostPort(c.Request.RemoteAddr); err == nil {
+		if forwardedFor := header.Get("X-Forwarded-For"); forwardedFor != "" {
+			clientIP = forwardedFor + ", " + clientIP
+		}
+		header.Set("X-Forwarded-For", clientIP)
+	}
+}
+
+// sendContainerResponse streams the container's response back to the client,
+// keeping its status, headers and body untouched.
+// The streamed responses, such as server-sent events or bodies of unknown length,
+// are flushed to the client chunk by chunk, each chunk extending the deadline.
+func sendContainerResponse(c *gin.Context, containerResp *http.Response, deadline *invocationDeadline) error {
+	defer containerResp.Body.Close()
+
+	streamed := isStreamedResponse(containerResp)
+
+	copyHeaders(c.Writer.Header(), containerResp.Header)
+	if streamed {
+		// Keeps the proxies in front of the engine from buffering the stream.
+		c.Header("X-Accel-Buffering", "no")
+	}
+	c.Status(containerResp.StatusCode)
+	c.Writer.WriteHeaderNow()
+
+	if !streamed {
+		_, err := io.Copy(c.Writer, containerResp.Body)
+		return err
+	}
+
+	c.Writer.Flush()
+
+	buffer := make([]byte, streamBufferSize)
+	for {
+		n, err := containerResp.Body.Read(buffer)
+		if n > 0 {
+			if _, writeErr := c.Writer.Write(buffer[:n]); writeErr != nil {
+				return writeErr
+			}
+			c.Writer.Flush()
+			deadline.extend()
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// isStreamedResponse reports whether a response is written as the function goes,
+// rather than as a body of known length.
+func isStreamedResponse(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == eventStreamMediaType || resp.ContentLength < 0
+}
+
+// copyHeaders copies the end-to-end headers of src into dst, leaving out the
+// hop-by-hop headers along with the ones listed in the Connection header.
+func copyHeaders(dst http.Header, src http.Header) {
+	for key, values := range src {
+		for _, value := range values {
+			dst.Add(key, value)
+		}
+	}
+
+	for _, connectionHeaders := range src.Values("Connection") {
+		for _, key := range strings.Split(connectionHeaders, ",") {
+			dst.Del(strings.TrimSpace(key))
+		}
+	}
+	for _, key := range hopByHopHeaders {
+		dst.Del(key)
+	}
+}
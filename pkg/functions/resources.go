@@ -0,0 +1,168 @@
+package functions
+
+import (
+	"fmt"
+
+	"Backend/models"
+	"Backend/utils"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-units"
+	"github.com/gin-gonic/gin"
+)
+
+// The smallest limits the Docker daemon accepts.
+const (
+	minMemoryBytes = 6 * units.MiB
+	minCpuShares   = 2
+)
+
+// tmpfsMountPoint is where the tmpfs of the function containers is mounted.
+const tmpfsMountPoint = "/tmp"
+
+// platformCeilings are the highest resource limits any function may declare.
+// The projects can lower them with their own ceilings.
+var platformCeilings = models.ResourceLimits{
+	MemoryBytes: utils.GetEnvBytes("FUNCTION_MAX_MEMORY", units.GiB),
+	CpuShares:   int64(utils.GetEnvInt("FUNCTION_MAX_CPU_SHARES", 2048)),
+	PidsLimit:   int64(utils.GetEnvInt("FUNCTION_MAX_PIDS", 512)),
+	TmpfsBytes:  utils.GetEnvBytes("FUNCTION_MAX_TMPFS", 256*units.MiB),
+}
+
+// ParseResourceSettings parses declared resource settings into resource limits.
+func ParseResourceSettings(settings models.ResourceSettings) (models.ResourceLimits, error) {
+	var limits models.ResourceLimits
+	var err error
+
+	if settings.Memory != "" {
+		limits.MemoryBytes, err = units.RAMInBytes(settings.Memory)
+		if err != nil {
+			return limits, fmt.Errorf("invalid memory %q: %v", settings.Memory, err)
+		}
+		if limits.MemoryBytes < minMemoryBytes {
+			return limits, fmt.Errorf("memory must be at least %s", units.BytesSize(minMemoryBytes))
+		}
+	}
+
+	if settings.CpuShares < 0 || (settings.CpuShares > 0 && settings.CpuShares < minCpuShares) {
+		return limits, fmt.Errorf("cpuShares must be at least %d", minCpuShares)
+	}
+	limits.CpuShares = settings.CpuShares
+
+	if settings.PidsLimit < 0 {
+		return limits, fmt.Errorf("pidsLimit cannot be negative")
+	}
+	limits.PidsLimit = settings.PidsLimit
+
+	if settings.TmpfsSize != "" {
+		limits.TmpfsBytes, err = units.RAMInBytes(settings.TmpfsSize)
+		if err != nil || limits.TmpfsBytes < 0 {
+			return limits, fmt.Errorf("invalid tmpfsSize %q", settings.TmpfsSize)
+		}
+	}
+
+	return limits, nil
+}
+
+// CheckResourceCeilings makes sure that none of the limits exceeds its ceiling.
+// A zero ceiling does not restrict the corresponding limit.
+func CheckResourceCeilings(limits models.ResourceLimits, ceilings models.ResourceLimits) error {
+	checks := []struct {
+		name    string
+		value   int64
+		ceiling int64
+		format  func(int64) string
+	}{
+		{"memory", limits.MemoryBytes, ceilings.MemoryBytes, formatBytes},
+		{"cpuShares", limits.CpuShares, ceilings.CpuShares, formatCount},
+		{"pidsLimit", limits.PidsLimit, ceilings.PidsLimit, formatCount},
+		{"tmpfsSize", limits.TmpfsBytes, ceilings.TmpfsBytes, formatBytes},
+	}
+
+	for _, check := range checks {
+		if check.ceiling > 0 && check.value > check.ceiling {
+			return fmt.Errorf("%s (%s) exceeds the limit of %s", check.name, check.format(check.value), check.format(check.ceiling))
+		}
+	}
+
+	return nil
+}
+
+// PlatformCeilings returns the highest resource limits any function may declare.
+func PlatformCeilings() models.ResourceLimits {
+	return platformCeilings
+}
+
+// checkProjectCeilings makes sure the limits do not exceed the ceilings of the project in the context.
+func checkProjectCeilings(c *gin.Context, limits models.ResourceLimits) error {
+	project, exists := utils.GetProjectFromContext(c)
+	if !exists {
+		return fmt.Errorf("project cannot be found from context")
+	}
+
+	return CheckResourceCeilings(limits, projectCeilings(project))
+}
+
+// projectCeilings returns the resource ceilings of a project, falling back to
+// the ceilings of the platform for the ones the project does not set.
+func projectCeilings(project *models.Project) models.ResourceLimits {
+	ceilings := project.Limits
+	if ceilings.MemoryBytes == 0 {
+		ceilings.MemoryBytes = platformCeilings.MemoryBytes
+	}
+	if ceilings.CpuShares == 0 {
+		ceilings.CpuShares = platformCeilings.CpuShares
+	}
+	if ceilings.PidsLimit == 0 {
+		ceilings.PidsLimit = platformCeilings.PidsLimit
+	}
+	if ceilings.TmpfsBytes == 0 {
+		ceilings.TmpfsBytes = platformCeilings.TmpfsBytes
+	}
+	return ceilings
+}
+
+// effectiveLimits returns the limits applied to the containers of a function
+// version. A function declaring no memory or pids limit gets the ceiling of its
+// project, so that no container ever runs unbounded.
+func effectiveLimits(function *models.Function, project *models.Project) models.ResourceLimits {
+	ceilings := projectCeilings(project)
+
+	limits := function.ResourceLimits
+	if limits.MemoryBytes == 0 {
+		limits.MemoryBytes = ceilings.MemoryBytes
+	}
+	if limits.PidsLimit == 0 {
+		limits.PidsLimit = ceilings.PidsLimit
+	}
+	return limits
+}
+
+// applyResourceLimits sets the resource limits on the host config of a container.
+// The memory limit covers the swap as well, so that a container cannot swap its way past it.
+func applyResourceLimits(hostConfig *container.HostConfig, limits models.ResourceLimits) {
+	if limits.MemoryBytes > 0 {
+		hostConfig.Memory = limits.MemoryBytes
+		hostConfig.MemorySwap = limits.MemoryBytes
+	}
+
+	hostConfig.CPUShares = limits.CpuShares
+
+	if limits.PidsLimit > 0 {
+		pidsLimit := limits.PidsLimit
+		hostConfig.PidsLimit = &pidsLimit
+	}
+
+	if limits.TmpfsBytes > 0 {
+		hostConfig.Tmpfs = map[string]string{
+			tmpfsMountPoint: fmt.Sprintf("rw,nosuid,nodev,size=%d", limits.TmpfsBytes),
+		}
+	}
+}
+
+func formatBytes(value int64) string {
+	return units.BytesSize(float64(value))
+}
+
+func formatCount(value int64) string {
+	return fmt.Sprintf("%d", value)
+}
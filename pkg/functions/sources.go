@@ -0,0 +1,169 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"Backend/models"
+	"Backend/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// sourceTarballName is the name the deployed tarball is archived under.
+	sourceTarballName = "source.tar.gz"
+	// generatedSourcesDir is the directory the files generated by the engine are archived under.
+	generatedSourcesDir = "generated"
+)
+
+// sourceKey returns the MinIO key of an archived file of a function version.
+func sourceKey(function *models.Function, name string) string {
+	return path.Join(function.ProjectId.String(), function.FunctionId, function.Version, name)
+}
+
+// archiveSources stores the deployed tarball of a function version in MinIO,
+// along with the files the engine generated into its source, such as the
+// Dockerfile and the entrypoint server.
+func archiveSources(function *models.Function, tarballPath string, extractionPath string, generated []string, log *buildLog) error {
+	log.append(fmt.Sprintf("archiving the source of %s@%s", function.FunctionId, function.Version))
+
+	if _, err := utils.UploadToMinIO(tarballPath, sourceKey(function, sourceTarballName)); err != nil {
+		return fmt.Errorf("cannot archive the source tarball: %v", err)
+	}
+
+	for _, name := range generated {
+		key := sourceKey(function, path.Join(generatedSourcesDir, filepath.ToSlash(name)))
+		_, err := utils.UploadFileToMinIO(filepath.Join(extractionPath, name), key, contentTypeOf(name))
+		if err != nil {
+			return fmt.Errorf("cannot archive the generated file %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// restoreGeneratedFiles writes the archived generated files of a function version
+// back into its extracted source, so that a rebuild uses the very same Dockerfile
+// and entrypoint server. The files are generated again when none were archived.
+func restoreGeneratedFiles(function *models.Function, extractionPath string, metadata models.Function) ([]string, error) {
+	prefix := sourceKey(function, generatedSourcesDir) + "/"
+
+	keys, err := utils.ListMinIO(prefix)
+	if err != nil && !utils.IsNotFoundMinIO(err) {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return generateSourceFiles(extractionPath, metadata)
+	}
+
+	var restored []string
+	for _, key := range keys {
+		name := filepath.FromSlash(strings.TrimPrefix(key, prefix))
+		target := filepath.Join(extractionPath, name)
+
+		if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		if err = utils.DownloadFromMinIO(key, target); err != nil {
+			return nil, err
+		}
+
+		restored = append(restored, name)
+	}
+
+	return restored, nil
+}
+
+func contentTypeOf(name string) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		return contentType
+	}
+	return "text/plain"
+}
+
+// DownloadFunctionSource sends the archived source tarball of a function version.
+func DownloadFunctionSource(c *gin.Context) {
+	functionEntity, err := utils.GetFunctionVersionFromContextParams(c)
+	if utils.HandleError(c, http.StatusNotFound, err, "failed to find function version") {
+		return
+	}
+
+	object, info, err := utils.GetFromMinIO(sourceKey(functionEntity, sourceTarballName))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if utils.IsNotFoundMinIO(err) {
+			status = http.StatusNotFound
+		}
+		utils.JsonError(c, status, err, "cannot find the archived source of the function version")
+		return
+	}
+	defer object.Close()
+
+	c.DataFromReader(
+		http.StatusOK,
+		info.Size,
+		"application/gzip",
+		object,
+		map[string]string{
+			"Content-Disposition": fmt.Sprintf(
+				`attachment; filename="%s-%s.tar.gz"`,
+				functionEntity.FunctionId,
+				functionEntity.Version,
+			),
+		},
+	)
+}
+
+// RebuildFunctionVersion queues a new build of a function version from its
+// archived source, with the Dockerfile and entrypoint server archived with it.
+// Once the rebuilt image is saved, saveFunctionImage drains the warm containers
+// of the version through drainReplaced, so that none keeps serving the old image.
+func RebuildFunctionVersion(c *gin.Context) {
+	functionEntity, err := utils.GetFunctionVersionFromContextParams(c)
+	if utils.HandleError(c, http.StatusNotFound, err, "failed to find function version") {
+		return
+	}
+
+	tarballPath := newUploadPath()
+	err = utils.DownloadFromMinIO(sourceKey(functionEntity, sourceTarballName), tarballPath)
+	if err != nil {
+		_ = os.Remove(tarballPath)
+
+		status := http.StatusInternalServerError
+		if utils.IsNotFoundMinIO(err) {
+			status = http.StatusNotFound
+		}
+		utils.JsonError(c, status, err, "cannot find the archived source of the function version")
+		return
+	}
+
+	// the archived source of a version is the deployed one, rebuilding it never changes
+	// the version content, and it is rebuilt for real to pick up fresher base images
+	queueSourceBuild(c, sourceBuild{
+		tarballPath: tarballPath,
+		noCache:     true,
+		prepare: func(extractionPath string, metadata models.Function) ([]string, error) {
+			if metadata.FunctionId != functionEntity.FunctionId || metadata.Version != functionEntity.Version {
+				return nil, fmt.Errorf(
+					"the archived source defines %s@%s instead of %s@%s",
+					metadata.FunctionId, metadata.Version,
+					functionEntity.FunctionId, functionEntity.Version,
+				)
+			}
+			return restoreGeneratedFiles(functionEntity, extractionPath, metadata)
+		},
+	})
+}
+
+// archiveJob returns the step of a build job archiving the source of the function version.
+func archiveJob(metadata models.Function, tarballPath string, extractionPath string, generated []string) func(context.Context, *buildLog) error {
+	return func(_ context.Context, log *buildLog) error {
+		return archiveSources(&metadata, tarballPath, extractionPath, generated, log)
+	}
+}
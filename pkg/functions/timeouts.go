@@ -0,0 +1,107 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"Backend/models"
+	"Backend/utils"
+)
+
+// statusClientClosedRequest is recorded for the invocations whose client went
+// away before the function answered.
+const statusClientClosedRequest = 499
+
+var (
+	// defaultInvocationTimeout bounds the invocations of the functions declaring no timeout.
+	defaultInvocationTimeout = utils.GetEnvDuration("FUNCTION_DEFAULT_TIMEOUT", 30*time.Second)
+	// platformMaxTimeout is the longest timeout a function is allowed to declare.
+	platformMaxTimeout = utils.GetEnvDuration("FUNCTION_MAX_TIMEOUT", 5*time.Minute)
+)
+
+// resolveInvocationTimeout validates a declared timeout and returns the
+// milliseconds to store on the function, 0 when none is declared.
+func resolveInvocationTimeout(timeout string) (int64, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+
+	duration, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q: %v", timeout, err)
+	}
+
+	if duration < time.Millisecond {
+		return 0, fmt.Errorf("timeout must be at least 1ms")
+	}
+
+	if duration > platformMaxTimeout {
+		return 0, fmt.Errorf("timeout (%s) exceeds the platform limit of %s", duration, platformMaxTimeout)
+	}
+
+	return duration.Milliseconds(), nil
+}
+
+// invocationTimeout returns the longest an invocation of a function version may take.
+// It never exceeds the platform maximum, even when it was lowered after the deployment.
+func invocationTimeout(function *models.Function) time.Duration {
+	timeout := defaultInvocationTimeout
+	if function.TimeoutMs > 0 {
+		timeout = time.Duration(function.TimeoutMs) * time.Millisecond
+	}
+
+	if timeout > platformMaxTimeout {
+		timeout = platformMaxTimeout
+	}
+	return timeout
+}
+
+// invocationDeadline cancels an invocation once it runs out of time. The function
+// has its timeout to start answering. A streamed answer gets the timeout again with
+// every chunk, so that a stream lasts as long as it keeps flowing, but never past
+// the platform maximum.
+type invocationDeadline struct {
+	timer   *time.Timer
+	timeout time.Duration
+	limit   time.Time
+	expired atomic.Bool
+}
+
+// startInvocationDeadline calls cancel once the invocation runs out of time.
+func startInvocationDeadline(timeout time.Duration, cancel context.CancelFunc) *invocationDeadline {
+	deadline := &invocationDeadline{
+		timeout: timeout,
+		limit:   time.Now().Add(platformMaxTimeout),
+	}
+	deadline.timer = time.AfterFunc(timeout, func() {
+		deadline.expired.Store(true)
+		cancel()
+	})
+
+	return deadline
+}
+
+// extend gives the invocation its timeout again, within the platform maximum.
+func (d *invocationDeadline) extend() {
+	if d.expired.Load() {
+		return
+	}
+
+	timeout := d.timeout
+	if remaining := time.Until(d.limit); remaining < timeout {
+		timeout = remaining
+	}
+	d.timer.Reset(timeout)
+}
+
+// stop releases the timer once the invocation is done.
+func (d *invocationDeadline) stop() {
+	d.timer.Stop()
+}
+
+// hasExpired reports whether the invocation ran out of time.
+func (d *invocationDeadline) hasExpired() bool {
+	return d.expired.Load()
+}
@@ -0,0 +1,238 @@
+package functions
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"net/http"
+	"time"
+
+	"Backend/models"
+	"Backend/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// versionStats summarizes the invocations served by a version of a function.
+type versionStats struct {
+	Version       string  `json:"version"`
+	Invocations   int64   `json:"invocations"`
+	Errors        int64   `json:"errors"`
+	Timeouts      int64   `json:"timeouts"`
+	ErrorRate     float64 `json:"errorRate"`
+	AvgDurationMs float64 `json:"avgDurationMs"`
+}
+
+// GetTrafficRule returns the traffic rule of a function, or of one of its aliases.
+func GetTrafficRule(c *gin.Context) {
+	project, _ := utils.GetProjectFromContext(c)
+
+	rule, err := findTrafficRule(project.ID.String(), c.Param("functionId"), c.Param("alias"))
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot find the traffic rule") {
+		return
+	}
+	if rule == nil {
+		utils.JsonError(c, http.StatusNotFound, fmt.Errorf("record not found"), "failed to find traffic rule")
+		return
+	}
+
+	utils.JsonSuccessH(c, http.StatusOK, "traffic rule", gin.H{"rule": rule})
+}
+
+// SetTrafficRule splits the invocations of a function, or of one of its aliases,
+// between versions in proportion to their weights. Requests carrying the sticky
+// header or cookie are always routed to the same version for a given value.
+// The rule only applies to the requests not pinning a version.
+func SetTrafficRule(c *gin.Context) {
+	project, _ := utils.GetProjectFromContext(c)
+	functionId := c.Param("functionId")
+	aliasName := c.Param("alias")
+
+	if aliasName != "" {
+		_, err := utils.GetAliasFromContextParams(c)
+		if utils.HandleError(c, http.StatusNotFound, err, "failed to find alias") {
+			return
+		}
+	}
+
+	var setTrafficRuleRequest models.SetTrafficRuleDTO
+	err := c.ShouldBindJSON(&setTrafficRuleRequest)
+	if utils.HandleError(c, http.StatusBadRequest, err, "cannot parse input") {
+		return
+	}
+
+	total := 0
+	seen := map[string]bool{}
+	for _, weight := range setTrafficRuleRequest.Weights {
+		if seen[weight.Version] {
+			utils.JsonError(c, http.StatusBadRequest, fmt.Errorf("version %s is weighted twice", weight.Version), "invalid traffic rule")
+			return
+		}
+		seen[weight.Version] = true
+		total += weight.Weight
+
+		_, err = findFunctionVersion(project.ID, functionId, weight.Version)
+		if utils.HandleError(c, http.StatusNotFound, err, "failed to find function version") {
+			return
+		}
+	}
+	if total == 0 {
+		utils.JsonError(c, http.StatusBadRequest, fmt.Errorf("the weights sum up to 0"), "invalid traffic rule")
+		return
+	}
+
+	rule := models.TrafficRule{
+		ProjectId:    project.ID,
+		FunctionId:   functionId,
+		Alias:        aliasName,
+		Weights:      setTrafficRuleRequest.Weights,
+		StickyHeader: setTrafficRuleRequest.StickyHeader,
+		StickyCookie: setTrafficRuleRequest.StickyCookie,
+	}
+
+	err = utils.DB.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "project_id"}, {Name: "function_id"}, {Name: "alias"}},
+			DoUpdates: clause.AssignmentColumns([]string{"weights", "sticky_header", "sticky_cookie", "updated_at"}),
+		}).
+		Create(&rule).
+		Error
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot set the traffic rule") {
+		return
+	}
+
+	utils.JsonSuccessH(c, http.StatusOK, "traffic rule set", gin.H{"rule": rule})
+}
+
+// DeleteTrafficRule removes the traffic rule of a function, or of one of its aliases.
+func DeleteTrafficRule(c *gin.Context) {
+	project, _ := utils.GetProjectFromContext(c)
+
+	rule, err := findTrafficRule(project.ID.String(), c.Param("functionId"), c.Param("alias"))
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot find the traffic rule") {
+		return
+	}
+	if rule == nil {
+		utils.JsonError(c, http.StatusNotFound, fmt.Errorf("record not found"), "failed to find traffic rule")
+		return
+	}
+
+	err = utils.DB.Delete(rule).Error
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot delete the traffic rule") {
+		return
+	}
+
+	utils.JsonSuccessH(c, http.StatusOK, "traffic rule deleted", gin.H{"rule": rule})
+}
+
+// GetInvocationStats summarizes per version the invocations of a function over
+// the period given by the since query parameter, 24h by default, so that the
+// error rates of the versions sharing the traffic can be compared.
+func GetInvocationStats(c *gin.Context) {
+	project, _ := utils.GetProjectFromContext(c)
+
+	since, err := time.ParseDuration(c.DefaultQuery("since", "24h"))
+	if utils.HandleError(c, http.StatusBadRequest, err, "invalid since duration") {
+		return
+	}
+
+	stats := []versionStats{}
+	err = utils.DB.
+		Model(&models.Invocation{}).
+		Select(
+			"version, count(*) AS invocations, "+
+				"sum(CASE WHEN status_code >= 500 THEN 1 ELSE 0 END) AS errors, "+
+				"sum(CASE WHEN timed_out THEN 1 ELSE 0 END) AS timeouts, "+
+				"avg(duration_ms) AS avg_duration_ms",
+		).
+		Where("function_id = ? AND project_id = ? AND created_at >= ?", c.Param("functionId"), project.ID.String(), time.Now().Add(-since)).
+		Group("version").
+		Order("version").
+		Scan(&stats).
+		Error
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot compute the invocation stats") {
+		return
+	}
+
+	for i := range stats {
+		if stats[i].Invocations > 0 {
+			stats[i].ErrorRate = float64(stats[i].Errors) / float64(stats[i].Invocations)
+		}
+	}
+
+	utils.JsonSuccessH(c, http.StatusOK, "invocation stats", gin.H{"since": since.String(), "versions": stats})
+}
+
+// findTrafficRule returns the traffic rule of a function or of one of its aliases, or nil when there is none.
+func findTrafficRule(projectId string, functionId string, alias string) (*models.TrafficRule, error) {
+	var rule models.TrafficRule
+	err := utils.DB.
+		Where("function_id = ? AND alias = ? AND project_id = ?", functionId, alias, projectId).
+		First(&rule).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+// pickVersion picks the version serving a request according to the weights of
+// the rule. Requests with a sticky key are hashed onto the weights instead of
+// being spread randomly, so that they keep hitting the same version.
+func pickVersion(c *gin.Context, rule *models.TrafficRule) string {
+	total := 0
+	for _, weight := range rule.Weights {
+		total += weight.Weight
+	}
+	if total <= 0 {
+		return rule.Weights[0].Version
+	}
+
+	var point int
+	if key := stickyKey(c, rule); key != "" {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(key))
+		point = int(hash.Sum32() % uint32(total))
+	} else if n, err := rand.Int(rand.Reader, big.NewInt(int64(total))); err == nil {
+		point = int(n.Int64())
+	}
+
+	for _, weight := range rule.Weights {
+		if point < weight.Weight {
+			return weight.Version
+		}
+		point -= weight.Weight
+	}
+
+	return rule.Weights[len(rule.Weights)-1].Version
+}
+
+func stickyKey(c *gin.Context, rule *models.TrafficRule) string {
+	if rule.StickyHeader != "" {
+		if value := c.GetHeader(rule.StickyHeader); value != "" {
+			return value
+		}
+	}
+	if rule.StickyCookie != "" {
+		if value, err := c.Cookie(rule.StickyCookie); err == nil && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// recordInvocation saves the outcome of an invocation, in the background so that it does not delay the response.
+func recordInvocation(invocation *models.Invocation) {
+	go func() {
+		if err := utils.DB.Create(invocation).Error; err != nil {
+			utils.Logger.Warnf("cannot record the invocation of %s@%s: %v", invocation.FunctionId, invocation.Version, err)
+		}
+	}()
+}
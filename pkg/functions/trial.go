@@ -0,0 +1,75 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+
+	"Backend/models"
+	"Backend/utils"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/lucsky/cuid"
+)
+
+// verifyImageContract starts a trial container of the given image and checks that
+// it serves the health endpoint on port 8080, the contract every function image
+// has to honor. It runs under the resource limits of the function, so that an image
+// unable to start within them is refused. The trial container is always removed afterwards.
+func verifyImageContract(ctx context.Context, image string, healthEndpoint string, limits models.ResourceLimits, log *buildLog) error {
+	log.append(fmt.Sprintf("verifying that the image answers %s on port 8080", healthEndpoint))
+
+	hostConfig := &container.HostConfig{
+		PortBindings: nat.PortMap{
+			"8080": []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: ""}},
+		},
+	}
+	applyResourceLimits(hostConfig, limits)
+
+	resp, err := utils.DockerClient.ContainerCreate(
+		ctx,
+		&container.Config{
+			Image: image,
+			ExposedPorts: nat.PortSet{
+				"8080": {},
+			},
+		},
+		hostConfig,
+		nil,
+		nil,
+		fmt.Sprintf(
+			"trial_%s",
+			cuid.New(),
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err := utils.DockerClient.ContainerRemove(context.Background(), resp.ID, types.ContainerRemoveOptions{
+			Force:         true,
+			RemoveVolumes: true,
+		})
+		if err != nil {
+			utils.Logger.Warnf("cannot remove trial container %s: %v", resp.ID, err)
+		}
+	}()
+
+	if err = utils.DockerClient.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return err
+	}
+
+	dynamicPort, err := fetchContainerDynamicPort(ctx, resp.ID)
+	if err != nil {
+		return err
+	}
+
+	if !pollContainerHealthCheck(dynamicPort, healthEndpoint) {
+		return fmt.Errorf("the image does not answer %s on port 8080", healthEndpoint)
+	}
+
+	log.append("the image honors the function contract")
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"Backend/models"
+	"Backend/utils"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RemoveProjectContainers drains the container pools of every function of a
+// project and removes its function containers, the busy ones included, so that
+// the network of the project can be removed along with it.
+func RemoveProjectContainers(ctx context.Context, project *models.Project) error {
+	for _, function := range project.Functions {
+		containerPool.drain(project.ID.String(), function.FunctionId, "")
+	}
+
+	// the drained containers are removed in the background and the busy ones once
+	// their invocation is over, so the ones still on the network are removed now
+	network, err := utils.DockerClient.NetworkInspect(ctx, project.NetworkName, types.NetworkInspectOptions{})
+	if client.IsErrNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for containerID, endpoint := range network.Containers {
+		if !strings.HasPrefix(endpoint.Name, functionContainerPrefix) {
+			continue
+		}
+
+		err = utils.KillAndRemoveContainer(containerID)
+		if err != nil && !client.IsErrNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteFunction undeploys a function: its containers, its images, locally and
+// in the registry, its archived sources, its aliases and traffic rules, and
+// every one of its versions.
+func DeleteFunction(c *gin.Context) {
+	project, _ := utils.GetProjectFromContext(c)
+	functionId := c.Param("functionId")
+
+	var versions []models.Function
+	err := utils.DB.
+		Where("function_id = ? AND project_id = ?", functionId, project.ID.String()).
+		Find(&versions).
+		Error
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot list the function versions") {
+		return
+	}
+	if len(versions) == 0 {
+		utils.JsonError(c, http.StatusNotFound, fmt.Errorf("record not found"), "failed to find function")
+		return
+	}
+
+	containerPool.drain(project.ID.String(), functionId, "")
+
+	err = RemoveFunctionImages(c, &versions[0])
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot remove docker image") {
+		return
+	}
+
+	for _, tag := range append(versionTags(versions), latestVersion) {
+		err = utils.DeleteRegistryManifest(ImageName(&versions[0]), tag)
+		if utils.HandleError(c, http.StatusInternalServerError, err, "cannot remove the image from the registry") {
+			return
+		}
+	}
+
+	err = utils.DeletePrefixFromMinIO(path.Join(project.ID.String(), functionId) + "/")
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot remove the archived sources") {
+		return
+	}
+
+	err = utils.DB.Transaction(func(tx *gorm.DB) error {
+		where := "function_id = ? AND project_id = ?"
+
+		if err := tx.Where(where, functionId, project.ID.String()).Delete(&models.Alias{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where(where, functionId, project.ID.String()).Delete(&models.TrafficRule{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where(where, functionId, project.ID.String()).Delete(&models.Function{}).Error
+	})
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot delete the function") {
+		return
+	}
+
+	utils.JsonSuccessH(c, http.StatusOK, "function deleted", gin.H{"functionId": functionId, "versions": versionTags(versions)})
+}
+
+// DeleteFunctionVersion deletes a version of a function: its containers, its
+// image, locally and in the registry, and its archived source. A version still
+// targeted by an alias or a traffic rule is kept until they stop pointing at it.
+func DeleteFunctionVersion(c *gin.Context) {
+	functionEntity, err := utils.GetFunctionVersionFromContextParams(c)
+	if utils.HandleError(c, http.StatusNotFound, err, "failed to find function version") {
+		return
+	}
+
+	err = checkVersionUnreferenced(functionEntity)
+	if utils.HandleError(c, http.StatusConflict, err, "the function version is still in use") {
+		return
+	}
+
+	var remaining []models.Function
+	err = utils.DB.
+		Where("function_id = ? AND project_id = ? AND version <> ?", functionEntity.FunctionId, functionEntity.ProjectId.String(), functionEntity.Version).
+		Order("created_at desc").
+		Find(&remaining).
+		Error
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot list the function versions") {
+		return
+	}
+
+	containerPool.drain(functionEntity.ProjectId.String(), functionEntity.FunctionId, functionEntity.Version)
+
+	err = removeVersionImage(c, functionEntity, remaining)
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot remove docker image") {
+		return
+	}
+
+	err = utils.DeletePrefixFromMinIO(sourceKey(functionEntity, "") + "/")
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot remove the archived source") {
+		return
+	}
+
+	err = utils.DB.Unscoped().Delete(functionEntity).Error
+	if utils.HandleError(c, http.StatusInternalServerError, err, "cannot delete the function version") {
+		return
+	}
+
+	utils.JsonSuccessH(c, http.StatusOK, "function version deleted", gin.H{"function": functionEntity})
+}
+
+// checkVersionUnreferenced makes sure no alias nor traffic rule targets a version.
+func checkVersionUnreferenced(functionEntity *models.Function) error {
+	aliases, err := listAliases(functionEntity.ProjectId, functionEntity.FunctionId)
+	if err != nil {
+		return err
+	}
+
+	var aliasNames []string
+	for _, alias := range aliases {
+		if alias.Version == functionEntity.Version {
+			aliasNames = append(aliasNames, alias.Name)
+		}
+	}
+	if len(aliasNames) > 0 {
+		return fmt.Errorf("version %s is pointed at by the aliases %s", functionEntity.Version, strings.Join(aliasNames, ", "))
+	}
+
+	var rules []models.TrafficRule
+	err = utils.DB.
+		Where("function_id = ? AND project_id = ?", functionEntity.FunctionId, functionEntity.ProjectId.String()).
+		Find(&rules).
+		Error
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		for _, weight := range rule.Weights {
+			if weight.Version == functionEntity.Version {
+				return fmt.Errorf("version %s is weighted by the traffic rule of %s", functionEntity.Version, ruleTarget(rule))
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeVersionImage removes the image of a version, locally and in the registry,
+// and moves the latest tag to the newest of the remaining versions, in the registry
+// as well. The manifest of an image shared with a remaining version, built from the
+// same source, is kept in the registry since deleting it would delete the tag of
+// that version too.
+func removeVersionImage(ctx context.Context, functionEntity *models.Function, remaining []models.Function) error {
+	_, err := utils.DockerClient.ImageRemove(ctx, imageReference(functionEntity, functionEntity.Version), types.ImageRemoveOptions{
+		Force:         true,
+		PruneChildren: true,
+	})
+	if err != nil && !client.IsErrNotFound(err) {
+		return err
+	}
+
+	shared := false
+	for _, version := range remaining {
+		if functionEntity.ImageDigest != "" && version.ImageDigest == functionEntity.ImageDigest {
+			shared = true
+		}
+	}
+
+	if shared {
+		utils.Logger.Infof("keeping the registry manifest of %s, shared with another version", imageReference(functionEntity, functionEntity.Version))
+	} else if err = utils.DeleteRegistryManifest(ImageName(functionEntity), functionEntity.Version); err != nil {
+		return err
+	}
+
+	if len(remaining) == 0 {
+		_, err = utils.DockerClient.ImageRemove(ctx, imageReference(functionEntity, latestVersion), types.ImageRemoveOptions{
+			Force:         true,
+			PruneChildren: true,
+		})
+		if err != nil && !client.IsErrNotFound(err) {
+			return err
+		}
+		return utils.DeleteRegistryManifest(ImageName(functionEntity), latestVersion)
+	}
+
+	// the registry latest tag may have gone with the deleted manifest, so it is pushed again
+	newest := &remaining[0]
+	newestImage := imageReference(newest, newest.Version)
+	if err = ensureLocalImage(ctx, newestImage); err != nil {
+		return fmt.Errorf("cannot move the latest tag of %s to %s: %v", ImageName(newest), newest.Version, err)
+	}
+
+	if err = utils.DockerClient.ImageTag(ctx, newestImage, imageReference(newest, latestVersion)); err != nil {
+		return fmt.Errorf("cannot move the latest tag of %s to %s: %v", ImageName(newest), newest.Version, err)
+	}
+
+	return pushFunctionImage(ctx, newestImage, []string{imageReference(newest, latestVersion)}, newBuildLog())
+}
+
+func versionTags(versions []models.Function) []string {
+	tags := make([]string, 0, len(versions))
+	for _, version := range versions {
+		tags = append(tags, version.Version)
+	}
+	return tags
+}
+
+func ruleTarget(rule models.TrafficRule) string {
+	if rule.Alias == "" {
+		return "the function"
+	}
+	return fmt.Sprintf("the alias %s", rule.Alias)
+}
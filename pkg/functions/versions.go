@@ -0,0 +1,84 @@
+package functions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"Backend/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errVersionConflict is returned when a version is deployed again with a different content.
+var errVersionConflict = errors.New("the version is already deployed with a different content")
+
+// checkVersionImmutable makes sure a deployed version keeps meaning the same content:
+// it can be deployed again with the same source hash, but replacing it with another
+// content requires force. The versions deployed before the source hashes were
+// recorded cannot be compared, so they also require force. It lets the deploy
+// requests fail early, saveMetadataToDB enforcing the same rule when the version is written.
+func checkVersionImmutable(functionMetadata *models.Function, force bool) error {
+	if force {
+		return nil
+	}
+
+	existing, err := findFunctionVersion(functionMetadata.ProjectId, functionMetadata.FunctionId, functionMetadata.Version)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing.SourceHash != "" && existing.SourceHash == functionMetadata.SourceHash {
+		return nil
+	}
+
+	return versionConflict(functionMetadata)
+}
+
+// versionConflict returns the error of a version deployed again with a different content.
+func versionConflict(functionMetadata *models.Function) error {
+	return fmt.Errorf(
+		"%w: %s@%s, bump the version or deploy with force to replace it",
+		errVersionConflict,
+		functionMetadata.FunctionId,
+		functionMetadata.Version,
+	)
+}
+
+// versionErrorStatus returns the HTTP status of an error raised while checking a deployed version.
+func versionErrorStatus(err error) int {
+	if errors.Is(err, errVersionConflict) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// forceRequested reports whether the deploy request sets the force form field or query parameter.
+func forceRequested(c *gin.Context) bool {
+	force, err := strconv.ParseBool(c.DefaultPostForm("force", c.DefaultQuery("force", "false")))
+	return err == nil && force
+}
+
+// hashFile returns the hex encoded sha256 of the file at the given path.
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
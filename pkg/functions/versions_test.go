@@ -0,0 +1,118 @@
+package functions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"Backend/models"
+	"Backend/utils"
+)
+
+func TestSaveMetadataToDBKeepsVersionsImmutable(t *testing.T) {
+	project := newTestProject(t)
+
+	save := func(sourceHash string, imageDigest string, force bool) (*models.Function, *models.Function, error) {
+		function := &models.Function{
+			Name:        "hello",
+			FunctionId:  "hello",
+			Version:     "1.0.0",
+			ProjectId:   project.ID,
+			SourceHash:  sourceHash,
+			ImageDigest: imageDigest,
+		}
+		previous, err := saveMetadataToDB(utils.DB, function, force)
+		return function, previous, err
+	}
+
+	created, previous, err := save("a", "sha256:1", false)
+	if err != nil {
+		t.Fatalf("save new version: %v", err)
+	}
+	if previous != nil {
+		t.Errorf("a new version has the previous version %v", previous)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	_, previous, err = save("a", "sha256:2", false)
+	if err != nil {
+		t.Fatalf("save the same content again: %v", err)
+	}
+	if previous == nil || previous.ImageDigest != "sha256:1" {
+		t.Errorf("got the previous version %v, want the one of image sha256:1", previous)
+	}
+
+	_, _, err = save("b", "sha256:3", false)
+	if !errors.Is(err, errVersionConflict) {
+		t.Fatalf("save another content: got %v, want %v", err, errVersionConflict)
+	}
+
+	replaced, previous, err := save("b", "sha256:3", true)
+	if err != nil {
+		t.Fatalf("force another content: %v", err)
+	}
+	if previous == nil || previous.ImageDigest != "sha256:2" {
+		t.Errorf("got the previous version %v, want the one of image sha256:2", previous)
+	}
+	if replaced.SourceHash != "b" || replaced.ImageDigest != "sha256:3" {
+		t.Errorf("the forced deploy saved %s and %s, want b and sha256:3", replaced.SourceHash, replaced.ImageDigest)
+	}
+	if !replaced.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("the version was created at %v, then at %v", created.CreatedAt, replaced.CreatedAt)
+	}
+}
+
+func TestImageTagsOfRebuiltOlderVersion(t *testing.T) {
+	project := newTestProject(t)
+
+	save := func(version string) []string {
+		t.Helper()
+
+		function := &models.Function{
+			Name:       "hello",
+			FunctionId: "hello",
+			Version:    version,
+			ProjectId:  project.ID,
+			SourceHash: "source-" + version,
+		}
+		if _, err := saveMetadataToDB(utils.DB, function, false); err != nil {
+			t.Fatalf("save %s: %v", version, err)
+		}
+
+		newest, err := isNewestVersion(utils.DB, function)
+		if err != nil {
+			t.Fatalf("isNewestVersion %s: %v", version, err)
+		}
+
+		// the versions are told apart by their creation time
+		time.Sleep(10 * time.Millisecond)
+
+		return getImageTags(function, newest)
+	}
+
+	latest := imageReference(&models.Function{FunctionId: "hello", ProjectId: project.ID}, latestVersion)
+
+	tests := []struct {
+		name       string
+		version    string
+		wantLatest bool
+	}{
+		{name: "first version", version: "1.0.0", wantLatest: true},
+		{name: "newer version", version: "2.0.0", wantLatest: true},
+		{name: "rebuilt older version", version: "1.0.0", wantLatest: false},
+		{name: "rebuilt newest version", version: "2.0.0", wantLatest: true},
+	}
+
+	for _, tt := range tests {
+		tags := save(tt.version)
+
+		hasLatest := false
+		for _, tag := range tags {
+			hasLatest = hasLatest || tag == latest
+		}
+		if hasLatest != tt.wantLatest {
+			t.Errorf("%s: got the tags %v, want latest tagged: %v", tt.name, tags, tt.wantLatest)
+		}
+	}
+}
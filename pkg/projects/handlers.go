@@ -28,8 +28,34 @@ func SetupRoutes(r *gin.Engine) {
 
 			functionsGroup := projectGroup.Group("functions")
 			{
+				functionsGroup.GET("", functions.ListFunctions)
 				functionsGroup.POST("/deploy", functions.DeployFunction)
-				functionsGroup.POST("/execute/:functionId", functions.ExecuteFunction)
+				functionsGroup.POST("/deploy/image", functions.DeployFunctionImage)
+				functionsGroup.Any("/execute/:functionId", functions.ExecuteFunction)
+				functionsGroup.Any("/execute/:functionId/*path", functions.ExecuteFunction)
+				functionsGroup.GET("/builds/:buildId", functions.GetBuild)
+				functionsGroup.GET("/builds/:buildId/logs", functions.StreamBuildLogs)
+
+				functionGroup := functionsGroup.Group(":functionId")
+				{
+					functionGroup.GET("", functions.GetFunction)
+					functionGroup.DELETE("", functions.DeleteFunction)
+					functionGroup.GET("/versions", functions.ListFunctionVersions)
+					functionGroup.DELETE("/versions/:version", functions.DeleteFunctionVersion)
+					functionGroup.GET("/versions/:version/source", functions.DownloadFunctionSource)
+					functionGroup.POST("/versions/:version/rebuild", functions.RebuildFunctionVersion)
+					functionGroup.GET("/aliases", functions.ListFunctionAliases)
+					functionGroup.GET("/aliases/:alias", functions.GetFunctionAlias)
+					functionGroup.PUT("/aliases/:alias", functions.SetFunctionAlias)
+					functionGroup.DELETE("/aliases/:alias", functions.DeleteFunctionAlias)
+					functionGroup.GET("/aliases/:alias/traffic", functions.GetTrafficRule)
+					functionGroup.PUT("/aliases/:alias/traffic", functions.SetTrafficRule)
+					functionGroup.DELETE("/aliases/:alias/traffic", functions.DeleteTrafficRule)
+					functionGroup.GET("/traffic", functions.GetTrafficRule)
+					functionGroup.PUT("/traffic", functions.SetTrafficRule)
+					functionGroup.DELETE("/traffic", functions.DeleteTrafficRule)
+					functionGroup.GET("/invocations/stats", functions.GetInvocationStats)
+				}
 			}
 
 			databasesGroup := projectGroup.Group("/databases")
@@ -89,9 +115,15 @@ func CreateProject(c *gin.Context) {
 		return
 	}
 
+	limits, err := parseProjectLimits(projectDto.Limits)
+	if utils.HandleError(c, http.StatusBadRequest, err, "invalid resource limits") {
+		return
+	}
+
 	project := models.Project{
 		Name:        strcase.ToKebab(projectDto.Name),
 		NetworkName: fmt.Sprintf("net_%s", strcase.ToSnake(projectDto.Name)),
+		Limits:      limits,
 	}
 
 	netResp, err := utils.DockerClient.NetworkCreate(c, project.NetworkName, types.NetworkCreate{})
@@ -174,6 +206,13 @@ func UpdateProject(c *gin.Context) {
 
 	p.Name = strcase.ToKebab(projectDto.Name)
 
+	if projectDto.Limits != nil {
+		p.Limits, err = parseProjectLimits(projectDto.Limits)
+		if utils.HandleError(c, http.StatusBadRequest, err, "invalid resource limits") {
+			return
+		}
+	}
+
 	err = utils.DB.Save(&p).Error
 	if err != nil {
 		utils.JsonError(
@@ -206,12 +245,19 @@ func DeleteProject(c *gin.Context) {
 		return
 	}
 
-	for _, function := range p.Functions {
-		_, err := utils.DockerClient.ImageRemove(c, function.FunctionId, types.ImageRemoveOptions{
-			Force:         true,
-			PruneChildren: true,
-		})
+	err := functions.RemoveProjectContainers(c, p)
+	if err != nil {
+		utils.JsonError(
+			c,
+			http.StatusInternalServerError,
+			err,
+			"cannot remove function containers",
+		)
+		return
+	}
 
+	for _, function := range p.Functions {
+		err := functions.RemoveFunctionImages(c, &function)
 		if err != nil {
 			utils.JsonError(
 				c,
@@ -240,7 +286,7 @@ func DeleteProject(c *gin.Context) {
 		}
 	}
 
-	err := utils.DockerClient.NetworkRemove(c, p.NetworkName)
+	err = utils.DockerClient.NetworkRemove(c, p.NetworkName)
 	if err != nil {
 		utils.JsonError(
 			c,
@@ -270,3 +316,18 @@ func DeleteProject(c *gin.Context) {
 	)
 
 }
+
+// parseProjectLimits parses the resource ceilings of a project, which cannot exceed
+// the ones of the platform. No settings leave the ceilings of the platform in place.
+func parseProjectLimits(settings *models.ResourceSettings) (models.ResourceLimits, error) {
+	if settings == nil {
+		return models.ResourceLimits{}, nil
+	}
+
+	limits, err := functions.ParseResourceSettings(*settings)
+	if err != nil {
+		return limits, err
+	}
+
+	return limits, functions.CheckResourceCeilings(limits, functions.PlatformCeilings())
+}
@@ -0,0 +1,170 @@
+package golang
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"Backend/models"
+	"Backend/pkg/runtimes"
+)
+
+const Language models.Language = "go"
+
+const (
+	moduleFile  = "go.mod"
+	defaultMain = "Handler"
+	binary      = "/out/function"
+)
+
+func init() {
+	runtimes.Register(runtime{})
+}
+
+// runtime builds Go modules exporting an http.Handler, or a handler function,
+// from their root package. The function is compiled with a generated main
+// package in a builder stage and shipped alone in a minimal image.
+type runtime struct{}
+
+func (runtime) Language() models.Language {
+	return Language
+}
+
+func (runtime) Detect(extractionPath string) bool {
+	_, err := os.Stat(filepath.Join(extractionPath, moduleFile))
+	return err == nil
+}
+
+// ReadDefinition reads the manifest of the function, go.mod having no place
+// for its name and version. The main is the name of the exported handler.
+func (runtime) ReadDefinition(extractionPath string) (*models.Definition, error) {
+	if !runtimes.HasManifest(extractionPath) {
+		return nil, fmt.Errorf("%s is required to read the name and version of a go function", runtimes.ManifestFile)
+	}
+
+	def, err := runtimes.ReadManifest(extractionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if def.Main == "" {
+		def.Main = defaultMain
+	}
+
+	// the go directive is the minimum version the module builds with,
+	// unless the manifest selects the version itself
+	def.RuntimeVersion = def.Engines["go"]
+	if def.RuntimeVersion == "" {
+		goVersion, err := readDirective(extractionPath, "go")
+		if err != nil {
+			return nil, err
+		}
+		if goVersion != "" {
+			def.RuntimeVersion = ">=" + goVersion
+		}
+	}
+
+	return def, nil
+}
+
+func (runtime) Versions() []string {
+	return []string{"1.21", "1.22", "1.23"}
+}
+
+func (runtime) DefaultVersion() string {
+	return "1.22"
+}
+
+func (runtime) BaseImage(models.Function) (string, error) {
+	return "gcr.io/distroless/static-debian12", nil
+}
+
+func (r runtime) BuilderStage(function models.Function, _ string) (*runtimes.BuilderStage, error) {
+	return &runtimes.BuilderStage{
+		Image: fmt.Sprintf("golang:%s", runtimes.RuntimeVersion(r, function)),
+		Runs: []string{
+			"go mod download",
+			fmt.Sprintf("CGO_ENABLED=0 go build -trimpath -o %s ./%s", binary, filepath.Dir(r.EntrypointFile())),
+		},
+		Artifacts: []string{binary},
+	}, nil
+}
+
+func (runtime) BuildSteps(string) []string {
+	return nil
+}
+
+func (runtime) Command() []string {
+	return []string{"/app/function"}
+}
+
+func (runtime) DockerfileTemplate() string {
+	return "templates/dockerfiles/base.tmpl"
+}
+
+func (runtime) EntrypointTemplate() string {
+	return "templates/entrypoints/entrypoint.go.tmpl"
+}
+
+func (runtime) EntrypointFile() string {
+	return "stackblox_entrypoint/main.go"
+}
+
+func (runtime) HealthEndpoint() string {
+	return runtimes.EntrypointHealthEndpoint
+}
+
+func (runtime) HealthCheck() []string {
+	return []string{"/app/function", "-healthcheck"}
+}
+
+// EntrypointData adds the module path to the data, the generated main
+// package importing the handler from the root package of the module.
+func (runtime) EntrypointData(function models.Function, extractionPath string) (any, error) {
+	module, err := readModulePath(extractionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		Main   string
+		Module string
+	}{
+		Main:   function.Main,
+		Module: module,
+	}, nil
+}
+
+func readModulePath(extractionPath string) (string, error) {
+	module, err := readDirective(extractionPath, "module")
+	if err != nil {
+		return "", err
+	}
+
+	if module == "" {
+		return "", fmt.Errorf("cannot find the module path in %s", moduleFile)
+	}
+
+	return module, nil
+}
+
+// readDirective returns the value of a single line directive of go.mod, or an empty string.
+func readDirective(extractionPath string, directive string) (string, error) {
+	file, err := os.Open(filepath.Join(extractionPath, moduleFile))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == directive {
+			return strings.Trim(fields[1], `"`), nil
+		}
+	}
+
+	return "", scanner.Err()
+}
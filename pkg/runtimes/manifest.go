@@ -0,0 +1,35 @@
+package runtimes
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"Backend/models"
+)
+
+// ManifestFile is the definition file of the functions whose language has no
+// native place for the name, version and engine settings of a function.
+const ManifestFile = "stackblox.json"
+
+// HasManifest reports whether the extracted function source ships a manifest.
+func HasManifest(extractionPath string) bool {
+	_, err := os.Stat(filepath.Join(extractionPath, ManifestFile))
+	return err == nil
+}
+
+// ReadManifest parses the manifest of the extracted function source.
+// It has the same shape as the package.json fields read by the engine.
+func ReadManifest(extractionPath string) (*models.Definition, error) {
+	raw, err := os.ReadFile(filepath.Join(extractionPath, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var def *models.Definition
+	if err = json.Unmarshal(raw, &def); err != nil {
+		return nil, err
+	}
+
+	return def, nil
+}
@@ -0,0 +1,105 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"Backend/models"
+	"Backend/pkg/runtimes"
+)
+
+const Language models.Language = "node-js"
+
+const (
+	definitionFile = "package.json"
+	lockFile       = "package-lock.json"
+)
+
+func init() {
+	runtimes.Register(runtime{})
+}
+
+// runtime builds JavaScript functions exporting a fetch-like handler from their package.json main.
+type runtime struct{}
+
+func (runtime) Language() models.Language {
+	return Language
+}
+
+func (runtime) Detect(extractionPath string) bool {
+	_, err := os.Stat(filepath.Join(extractionPath, definitionFile))
+	return err == nil
+}
+
+func (runtime) ReadDefinition(extractionPath string) (*models.Definition, error) {
+	definitionRawData, err := os.ReadFile(filepath.Join(extractionPath, definitionFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var def *models.Definition
+	if err = json.Unmarshal(definitionRawData, &def); err != nil {
+		return nil, err
+	}
+
+	def.RuntimeVersion = def.Engines["node"]
+
+	return def, nil
+}
+
+func (runtime) Versions() []string {
+	return []string{"18", "20", "22"}
+}
+
+func (runtime) DefaultVersion() string {
+	return "18"
+}
+
+func (r runtime) BaseImage(function models.Function) (string, error) {
+	return fmt.Sprintf("node:%s", runtimes.RuntimeVersion(r, function)), nil
+}
+
+// BuildSteps is empty, the dependencies being installed by Dependencies.
+func (runtime) BuildSteps(string) []string {
+	return nil
+}
+
+// Dependencies installs the production dependencies from the package manifests,
+// exactly as locked when there is a lockfile.
+func (runtime) Dependencies(extractionPath string) ([]string, []string) {
+	if _, err := os.Stat(filepath.Join(extractionPath, lockFile)); err != nil {
+		return []string{definitionFile}, []string{"npm install --omit=dev"}
+	}
+	return []string{definitionFile, lockFile}, []string{"npm ci --omit=dev"}
+}
+
+// IgnoredPaths leaves out the dependencies installed locally, which are installed again in the image.
+func (runtime) IgnoredPaths() []string {
+	return []string{"node_modules"}
+}
+
+func (r runtime) Command() []string {
+	return []string{"node", r.EntrypointFile()}
+}
+
+func (runtime) DockerfileTemplate() string {
+	return "templates/dockerfiles/base.tmpl"
+}
+
+func (runtime) EntrypointTemplate() string {
+	return "templates/entrypoints/entrypoint.js.tmpl"
+}
+
+func (runtime) EntrypointFile() string {
+	return "entrypoint.js"
+}
+
+func (runtime) HealthEndpoint() string {
+	return runtimes.EntrypointHealthEndpoint
+}
+
+func (runtime) HealthCheck() []string {
+	return []string{"curl", "-f", "http://localhost:8080" + runtimes.EntrypointHealthEndpoint}
+}
@@ -0,0 +1,182 @@
+package python
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"Backend/models"
+	"Backend/pkg/runtimes"
+	"github.com/pelletier/go-toml/v2"
+)
+
+const Language models.Language = "python"
+
+const (
+	projectFile      = "pyproject.toml"
+	requirementsFile = "requirements.txt"
+	defaultMain      = "main.py"
+)
+
+func init() {
+	runtimes.Register(runtime{})
+}
+
+// pyproject is the part of pyproject.toml read by the engine.
+// The engine settings live in the [tool.stackblox] table.
+type pyproject struct {
+	Project struct {
+		Name           string   `toml:"name"`
+		Version        string   `toml:"version"`
+		RequiresPython string   `toml:"requires-python"`
+		Description    string   `toml:"description"`
+		Dependencies   []string `toml:"dependencies"`
+	} `toml:"project"`
+	Tool struct {
+		Stackblox struct {
+			Main                      string `toml:"main"`
+			models.DefinitionSettings `toml:",inline"`
+		} `toml:"stackblox"`
+	} `toml:"tool"`
+}
+
+// runtime builds Python functions exposing a handler(request, env) function from their main module.
+type runtime struct{}
+
+func (runtime) Language() models.Language {
+	return Language
+}
+
+func (runtime) Detect(extractionPath string) bool {
+	return exists(extractionPath, projectFile) || exists(extractionPath, requirementsFile)
+}
+
+// ReadDefinition reads pyproject.toml. The functions only shipping a requirements
+// file declare their name, version and engine settings in the stackblox manifest
+// instead, the python version constraint going in its engines.python field.
+func (runtime) ReadDefinition(extractionPath string) (*models.Definition, error) {
+	if !exists(extractionPath, projectFile) {
+		return readManifest(extractionPath)
+	}
+
+	project, err := readProject(extractionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	def := &models.Definition{
+		Name:        project.Project.Name,
+		Description: project.Project.Description,
+		Version:     project.Project.Version,
+		Main:        project.Tool.Stackblox.Main,
+		Stackblox:   project.Tool.Stackblox.DefinitionSettings,
+
+		RuntimeVersion: project.Project.RequiresPython,
+	}
+	if def.Main == "" {
+		def.Main = defaultMain
+	}
+
+	return def, nil
+}
+
+func (runtime) Versions() []string {
+	return []string{"3.10", "3.11", "3.12", "3.13"}
+}
+
+func (runtime) DefaultVersion() string {
+	return "3.12"
+}
+
+func (r runtime) BaseImage(function models.Function) (string, error) {
+	return fmt.Sprintf("python:%s-slim", runtimes.RuntimeVersion(r, function)), nil
+}
+
+// BuildSteps installs the requirements file when there is one, and the
+// dependencies declared in pyproject.toml otherwise.
+func (runtime) BuildSteps(extractionPath string) []string {
+	if exists(extractionPath, requirementsFile) {
+		return []string{fmt.Sprintf("pip install --no-cache-dir -r %s", requirementsFile)}
+	}
+
+	project, err := readProject(extractionPath)
+	if err != nil || len(project.Project.Dependencies) == 0 {
+		return nil
+	}
+
+	var quoted []string
+	for _, dependency := range project.Project.Dependencies {
+		quoted = append(quoted, fmt.Sprintf("'%s'", strings.ReplaceAll(dependency, "'", "")))
+	}
+
+	return []string{fmt.Sprintf("pip install --no-cache-dir %s", strings.Join(quoted, " "))}
+}
+
+func (r runtime) Command() []string {
+	return []string{"python", r.EntrypointFile()}
+}
+
+func (runtime) DockerfileTemplate() string {
+	return "templates/dockerfiles/python.tmpl"
+}
+
+func (runtime) EntrypointTemplate() string {
+	return "templates/entrypoints/entrypoint.py.tmpl"
+}
+
+func (runtime) EntrypointFile() string {
+	return "entrypoint.py"
+}
+
+func (runtime) HealthEndpoint() string {
+	return runtimes.EntrypointHealthEndpoint
+}
+
+func (runtime) HealthCheck() []string {
+	return []string{
+		"python", "-c",
+		"import urllib.request; urllib.request.urlopen('http://localhost:8080" + runtimes.EntrypointHealthEndpoint + "')",
+	}
+}
+
+func readManifest(extractionPath string) (*models.Definition, error) {
+	if !runtimes.HasManifest(extractionPath) {
+		return nil, fmt.Errorf(
+			"%s or %s is required to read the name and version of a python function",
+			projectFile,
+			runtimes.ManifestFile,
+		)
+	}
+
+	def, err := runtimes.ReadManifest(extractionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	def.RuntimeVersion = def.Engines["python"]
+	if def.Main == "" {
+		def.Main = defaultMain
+	}
+
+	return def, nil
+}
+
+func readProject(extractionPath string) (*pyproject, error) {
+	raw, err := os.ReadFile(filepath.Join(extractionPath, projectFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var project pyproject
+	if err = toml.Unmarshal(raw, &project); err != nil {
+		return nil, err
+	}
+
+	return &project, nil
+}
+
+func exists(extractionPath string, name string) bool {
+	_, err := os.Stat(filepath.Join(extractionPath, name))
+	return err == nil
+}
@@ -0,0 +1,82 @@
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"Backend/pkg/runtimes"
+)
+
+func TestReadDefinition(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		wantName    string
+		wantVersion string
+		wantMain    string
+		wantRuntime string
+		wantErr     bool
+	}{
+		{
+			name: "pyproject",
+			files: map[string]string{
+				projectFile: "[project]\nname = \"hello\"\nversion = \"1.0.0\"\nrequires-python = \">=3.11\"\n" +
+					"[tool.stackblox]\nmain = \"app.py\"\n",
+			},
+			wantName:    "hello",
+			wantVersion: "1.0.0",
+			wantMain:    "app.py",
+			wantRuntime: ">=3.11",
+		},
+		{
+			name: "requirements with a manifest",
+			files: map[string]string{
+				requirementsFile:      "requests\n",
+				runtimes.ManifestFile: `{"name": "hello", "version": "1.2.0", "engines": {"python": "3.12"}}`,
+			},
+			wantName:    "hello",
+			wantVersion: "1.2.0",
+			wantMain:    defaultMain,
+			wantRuntime: "3.12",
+		},
+		{
+			name:    "requirements alone",
+			files:   map[string]string{requirementsFile: "requests\n"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !(runtime{}).Detect(dir) {
+				t.Fatalf("the source is not detected as a python function")
+			}
+
+			def, err := runtime{}.ReadDefinition(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if def.Name != tt.wantName || def.Version != tt.wantVersion {
+				t.Errorf("got %s@%s, want %s@%s", def.Name, def.Version, tt.wantName, tt.wantVersion)
+			}
+			if def.Main != tt.wantMain {
+				t.Errorf("got main %q, want %q", def.Main, tt.wantMain)
+			}
+			if def.RuntimeVersion != tt.wantRuntime {
+				t.Errorf("got runtime version %q, want %q", def.RuntimeVersion, tt.wantRuntime)
+			}
+		})
+	}
+}
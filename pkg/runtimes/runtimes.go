@@ -0,0 +1,289 @@
+package runtimes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"Backend/models"
+)
+
+// Runtime describes everything the engine needs to know to build and run the
+// functions written for a language. Runtimes register themselves with Register
+// from the init function of their own package.
+type Runtime interface {
+	// Language is the identifier stored on the functions of the runtime.
+	Language() models.Language
+	// Detect reports whether the extracted function source is written for the runtime.
+	Detect(extractionPath string) bool
+	// ReadDefinition parses the definition file of the extracted function source.
+	ReadDefinition(extractionPath string) (*models.Definition, error)
+	// Versions returns the language versions functions can select, oldest first.
+	Versions() []string
+	// DefaultVersion is the version of the functions not selecting one.
+	DefaultVersion() string
+	// BaseImage returns the image the function image is built from.
+	BaseImage(function models.Function) (string, error)
+	// BuildSteps returns the commands run while building the function image.
+	BuildSteps(extractionPath string) []string
+	// Command returns the command starting the entrypoint server in the container.
+	Command() []string
+	// DockerfileTemplate is the path of the Dockerfile template of the runtime.
+	DockerfileTemplate() string
+	// EntrypointTemplate is the path of the entrypoint server template of the runtime.
+	EntrypointTemplate() string
+	// EntrypointFile is the path the entrypoint server is written to in the function source.
+	EntrypointFile() string
+	// HealthEndpoint is the path answering 200 once the entrypoint server is ready.
+	HealthEndpoint() string
+	// HealthCheck returns the command the image health check runs inside the container.
+	HealthCheck() []string
+}
+
+// MultiStageRuntime is implemented by the runtimes compiling functions in a
+// builder stage. The final image then only receives the stage artifacts.
+type MultiStageRuntime interface {
+	BuilderStage(function models.Function, extractionPath string) (*BuilderStage, error)
+}
+
+// EntrypointDataRuntime is implemented by the runtimes whose entrypoint template
+// needs more than the function main. Other runtimes render it with EntrypointData.
+type EntrypointDataRuntime interface {
+	EntrypointData(function models.Function, extractionPath string) (any, error)
+}
+
+// DependencyRuntime is implemented by the runtimes installing the function
+// dependencies from a few manifest files. Those files are copied and the
+// dependencies installed before the rest of the source, so that the layer
+// holding the dependencies is reused as long as the manifests do not change.
+type DependencyRuntime interface {
+	Dependencies(extractionPath string) (files []string, runs []string)
+}
+
+// IgnoreRuntime is implemented by the runtimes whose functions hold paths that
+// must never be sent to the image build, such as locally installed dependencies.
+type IgnoreRuntime interface {
+	IgnoredPaths() []string
+}
+
+// DockerfileData is the data rendered into the Dockerfile templates.
+type DockerfileData struct {
+	Builder         *BuilderStage
+	BaseImage       string
+	DependencyFiles []string
+	DependencyRuns  []string
+	Runs            []string
+	Cmd             []string
+	HealthEndpoint  string
+	HealthCheck     []string
+}
+
+// BuilderStage is the stage building the function artifacts of a multi-stage Dockerfile.
+type BuilderStage struct {
+	Image     string
+	Runs      []string
+	Artifacts []string
+}
+
+// EntrypointData is the default data rendered into the entrypoint templates.
+type EntrypointData struct {
+	Main string
+}
+
+// DefaultHealthEndpoint is the health endpoint of the images whose server is not
+// generated by the engine, such as the custom Dockerfiles and the prebuilt images.
+const DefaultHealthEndpoint = "/health"
+
+// EntrypointHealthEndpoint is the health endpoint of the entrypoint servers generated
+// by the engine. It is reserved so that it does not shadow a route of the functions,
+// which receive every other path.
+const EntrypointHealthEndpoint = "/__stackblox/health"
+
+// DockerignoreFile is the file listing the paths left out of the image build.
+const DockerignoreFile = ".dockerignore"
+
+// ignoredPaths are left out of the image build of every generated function.
+var ignoredPaths = []string{".git", ".hg", ".svn"}
+
+var (
+	mu       sync.RWMutex
+	registry []Runtime
+)
+
+// Register adds a runtime to the registry. Runtimes are detected in registration order.
+func Register(runtime Runtime) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, registered := range registry {
+		if registered.Language() == runtime.Language() {
+			panic(fmt.Sprintf("runtime %s registered twice", runtime.Language()))
+		}
+	}
+
+	registry = append(registry, runtime)
+}
+
+// Get returns the runtime of the given language.
+func Get(language models.Language) (Runtime, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, runtime := range registry {
+		if runtime.Language() == language {
+			return runtime, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported language: %s", language)
+}
+
+// Detect returns the first registered runtime recognizing the extracted function source.
+func Detect(extractionPath string) (Runtime, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, runtime := range registry {
+		if runtime.Detect(extractionPath) {
+			return runtime, nil
+		}
+	}
+
+	return nil, fmt.Errorf("cannot detect the runtime of the function")
+}
+
+// RuntimeVersion returns the language version selected by the function, or the
+// default version of its runtime for the functions deployed before the selection.
+func RuntimeVersion(runtime Runtime, function models.Function) string {
+	if function.RuntimeVersion == "" {
+		return runtime.DefaultVersion()
+	}
+	return function.RuntimeVersion
+}
+
+// GenerateDockerfileContent renders the Dockerfile of the given function.
+func GenerateDockerfileContent(metadata models.Function, extractionPath string) (string, error) {
+	runtime, err := Get(metadata.Language)
+	if err != nil {
+		return "", err
+	}
+
+	baseImage, err := runtime.BaseImage(metadata)
+	if err != nil {
+		return "", err
+	}
+
+	data := DockerfileData{
+		BaseImage:      baseImage,
+		Runs:           runtime.BuildSteps(extractionPath),
+		Cmd:            runtime.Command(),
+		HealthEndpoint: runtime.HealthEndpoint(),
+		HealthCheck:    runtime.HealthCheck(),
+	}
+
+	if dependencies, ok := runtime.(DependencyRuntime); ok {
+		data.DependencyFiles, data.DependencyRuns = dependencies.Dependencies(extractionPath)
+	}
+
+	if multiStage, ok := runtime.(MultiStageRuntime); ok {
+		data.Builder, err = multiStage.BuilderStage(metadata, extractionPath)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return render(runtime.DockerfileTemplate(), data)
+}
+
+// GenerateEntrypointContent renders the entrypoint server of the given function.
+// It returns the content and the path, relative to the function source, to write it to.
+func GenerateEntrypointContent(metadata models.Function, extractionPath string) (string, string, error) {
+	runtime, err := Get(metadata.Language)
+	if err != nil {
+		return "", "", err
+	}
+
+	var data any = EntrypointData{
+		Main: metadata.Main,
+	}
+
+	if custom, ok := runtime.(EntrypointDataRuntime); ok {
+		data, err = custom.EntrypointData(metadata, extractionPath)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	content, err := render(runtime.EntrypointTemplate(), data)
+	if err != nil {
+		return "", "", err
+	}
+
+	return content, runtime.EntrypointFile(), nil
+}
+
+// GenerateDockerignoreContent renders the .dockerignore of the given function: the
+// one shipped with the function, if any, followed by the VCS directories and the
+// paths ignored by the runtime. The generated files are always kept in the build.
+func GenerateDockerignoreContent(metadata models.Function, extractionPath string) (string, error) {
+	runtime, err := Get(metadata.Language)
+	if err != nil {
+		return "", err
+	}
+
+	var content strings.Builder
+
+	shipped, err := os.ReadFile(filepath.Join(extractionPath, DockerignoreFile))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if len(shipped) > 0 {
+		content.Write(shipped)
+		if !bytes.HasSuffix(shipped, []byte("\n")) {
+			content.WriteString("\n")
+		}
+	}
+
+	paths := append([]string{}, ignoredPaths...)
+	if ignore, ok := runtime.(IgnoreRuntime); ok {
+		paths = append(paths, ignore.IgnoredPaths()...)
+	}
+	for _, path := range paths {
+		content.WriteString(path + "\n")
+	}
+
+	for _, kept := range []string{"Dockerfile", runtime.EntrypointFile()} {
+		content.WriteString("!" + kept + "\n")
+	}
+
+	return content.String(), nil
+}
+
+func render(templatePath string, data any) (string, error) {
+	var tpl bytes.Buffer
+
+	tmpl, err := template.
+		New(filepath.Base(templatePath)).
+		Funcs(template.FuncMap{"json": toJson}).
+		ParseFiles(templatePath)
+	if err != nil {
+		return "", err
+	}
+
+	err = tmpl.Execute(&tpl, data)
+	if err != nil {
+		return "", err
+	}
+
+	return tpl.String(), nil
+}
+
+func toJson(value any) (string, error) {
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
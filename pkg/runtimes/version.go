@@ -0,0 +1,192 @@
+package runtimes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// comparators are the operators a version constraint can use, longest first
+// so that the two characters ones are matched before their prefixes.
+var comparators = []string{">=", "<=", "==", "!=", "~=", ">", "<", "=", "^", "~"}
+
+// SelectVersion returns the newest version allowed by the runtime satisfying
+// the constraint, or the default version of the runtime when there is none.
+//
+// Constraints follow the npm and PEP 440 syntaxes: comparators separated by
+// spaces or commas must all match, alternatives are separated by "||" and
+// hyphen ranges such as "18 - 20" include both bounds. The allowed versions are
+// floating image tags such as "20" or "3.12", standing for the newest release of
+// their line: they cannot satisfy an upper bound or an equality more precise
+// than themselves, as "<=18.5" or "=18.5" for "18", which resolves to 18.20.
+func SelectVersion(runtime Runtime, constraint string) (string, error) {
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "" {
+		return runtime.DefaultVersion(), nil
+	}
+
+	allowed := runtime.Versions()
+	for i := len(allowed) - 1; i >= 0; i-- {
+		candidate, ok := parseVersion(allowed[i])
+		if !ok {
+			continue
+		}
+
+		satisfied, err := satisfies(candidate, constraint)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s version constraint %q: %v", runtime.Language(), constraint, err)
+		}
+		if satisfied {
+			return allowed[i], nil
+		}
+	}
+
+	return "", fmt.Errorf(
+		"unsupported %s version %q, supported versions are %s",
+		runtime.Language(),
+		constraint,
+		strings.Join(allowed, ", "),
+	)
+}
+
+func satisfies(candidate []int, constraint string) (bool, error) {
+	for _, alternative := range strings.Split(constraint, "||") {
+		matched := true
+
+		tokens := strings.Fields(strings.ReplaceAll(alternative, ",", " "))
+		for i := 0; i < len(tokens); i++ {
+			token := tokens[i]
+			// the comparator may be separated from its version, as in ">= 18"
+			if isComparator(token) && i+1 < len(tokens) {
+				i++
+				token += tokens[i]
+			}
+
+			bounds := []string{token}
+			// a hyphen range, as in "18 - 20", is made of both its bounds
+			if i+2 < len(tokens) && tokens[i+1] == "-" {
+				bounds = []string{">=" + token, "<=" + tokens[i+2]}
+				i += 2
+			}
+
+			for _, bound := range bounds {
+				ok, err := matches(candidate, bound)
+				if err != nil {
+					return false, err
+				}
+				matched = matched && ok
+			}
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func matches(candidate []int, comparator string) (bool, error) {
+	op := ""
+	for _, prefix := range comparators {
+		if strings.HasPrefix(comparator, prefix) {
+			op = prefix
+			break
+		}
+	}
+
+	raw := strings.TrimSpace(strings.TrimPrefix(comparator, op))
+	wanted, ok := parseVersion(raw)
+	if !ok {
+		return false, fmt.Errorf("cannot parse version %q", raw)
+	}
+	if len(wanted) == 0 {
+		return op != "!=" && op != "<" && op != ">", nil
+	}
+
+	cmp := compareVersions(candidate, wanted)
+	// a floating tag less precise than the version resolves to the newest release
+	// of its line, which is above the version rather than equal to it
+	floating := cmp == 0 && len(wanted) > len(candidate)
+
+	switch op {
+	case ">=":
+		return cmp >= 0, nil
+	case ">":
+		return cmp > 0 || floating, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp < 0 || (cmp == 0 && !floating), nil
+	case "", "=", "==":
+		return cmp == 0 && !floating, nil
+	case "!=":
+		return cmp != 0 || floating, nil
+	case "^":
+		return cmp >= 0 && candidate[0] == wanted[0], nil
+	case "~":
+		return cmp >= 0 && samePrefix(candidate, wanted, 2), nil
+	case "~=":
+		return cmp >= 0 && samePrefix(candidate, wanted, len(wanted)-1), nil
+	}
+
+	return false, fmt.Errorf("unknown comparator %q", op)
+}
+
+// parseVersion parses the numeric components of a version, stopping at the
+// first wildcard: "18.x" gives [18] and "*" gives no component at all.
+func parseVersion(raw string) ([]int, bool) {
+	raw = strings.TrimPrefix(strings.TrimSpace(raw), "v")
+
+	var components []int
+	for _, part := range strings.Split(raw, ".") {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		components = append(components, n)
+	}
+
+	return components, true
+}
+
+// compareVersions compares two versions up to the precision of the least precise one.
+func compareVersions(a []int, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// samePrefix reports whether the candidate shares the first n components of
+// the wanted version, up to the precision of the latter. A floating candidate
+// missing one of these components does not share it.
+func samePrefix(candidate []int, wanted []int, n int) bool {
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n && i < len(wanted); i++ {
+		if i >= len(candidate) || candidate[i] != wanted[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isComparator(token string) bool {
+	for _, op := range comparators {
+		if token == op {
+			return true
+		}
+	}
+	return false
+}
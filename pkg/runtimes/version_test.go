@@ -0,0 +1,80 @@
+package runtimes
+
+import (
+	"testing"
+
+	"Backend/models"
+)
+
+// versionedRuntime is a runtime only able to list its versions.
+type versionedRuntime struct {
+	Runtime
+	versions       []string
+	defaultVersion string
+}
+
+func (r versionedRuntime) Language() models.Language {
+	return "test"
+}
+
+func (r versionedRuntime) Versions() []string {
+	return r.versions
+}
+
+func (r versionedRuntime) DefaultVersion() string {
+	return r.defaultVersion
+}
+
+func TestSelectVersion(t *testing.T) {
+	node := versionedRuntime{versions: []string{"18", "20", "22"}, defaultVersion: "18"}
+	python := versionedRuntime{versions: []string{"3.10", "3.11", "3.12", "3.13"}, defaultVersion: "3.12"}
+
+	tests := []struct {
+		name       string
+		runtime    versionedRuntime
+		constraint string
+		want       string
+		wantErr    bool
+	}{
+		{"no constraint", node, "", "18", false},
+		{"exact major", node, "20", "20", false},
+		{"wildcard", node, "*", "22", false},
+		{"x range", node, "20.x", "20", false},
+		{"lower bound", node, ">=18", "22", false},
+		{"spaced comparator", node, ">= 18 < 22", "20", false},
+		{"exclusive upper bound", node, "<22", "20", false},
+		{"caret", node, "^20.1.0", "20", false},
+		{"tilde of a major", node, "~20", "20", false},
+		{"alternatives", node, "^16 || ^20", "20", false},
+		{"lower bound more precise", node, ">18.5", "22", false},
+		{"precise bound on the newest major", node, ">=18.5 <21", "20", false},
+		{"hyphen range", node, "18 - 20", "20", false},
+		{"hyphen range of one major", node, "18 - 18", "18", false},
+		{"hyphen range with precise bounds", node, "18.2.0 - 20.5", "18", false},
+		{"precise upper bound", node, "<=18.5", "", true},
+		{"precise equality", node, "=18.5", "", true},
+		{"bare precise version", node, "18.5", "", true},
+		{"precise tilde", node, "~18.5", "", true},
+		{"precise inequality", node, "!=22.1 >=22", "22", false},
+		{"unsupported major", node, "16", "", true},
+		{"invalid version", node, ">=abc", "", true},
+		{"pep 440 range", python, ">=3.10,<3.13", "3.12", false},
+		{"compatible release", python, "~=3.11", "3.13", false},
+		{"compatible patch release", python, "~=3.11.2", "3.11", false},
+		{"equality of a less precise version", python, "==3", "3.13", false},
+		{"exclusive upper bound of a less precise version", python, "<3", "", true},
+		{"precise equality of a minor", python, "==3.12.1", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectVersion(tt.runtime, tt.constraint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SelectVersion(%q) error = %v, want error %v", tt.constraint, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SelectVersion(%q) = %q, want %q", tt.constraint, got, tt.want)
+			}
+		})
+	}
+}
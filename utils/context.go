@@ -50,3 +50,57 @@ func GetFunctionFromContextParams(c *gin.Context) (*models.Function, error) {
 
 	return functionEntity, err
 }
+
+// GetBuildFromContextParams retrieves the build of the project in the context based on the given build id.
+func GetBuildFromContextParams(c *gin.Context) (*models.Build, error) {
+	project, exists := GetProjectFromContext(c)
+	if !exists {
+		return nil, fmt.Errorf("project not exists in the context")
+	}
+
+	buildId := c.Param("buildId")
+
+	var buildEntity *models.Build
+	err := DB.
+		Where("id = ? AND project_id = ?", buildId, project.ID.String()).
+		First(&buildEntity).
+		Error
+
+	return buildEntity, err
+}
+
+// GetFunctionVersionFromContextParams retrieves the function entity of the given function tag and version.
+func GetFunctionVersionFromContextParams(c *gin.Context) (*models.Function, error) {
+	project, exists := GetProjectFromContext(c)
+	if !exists {
+		return nil, fmt.Errorf("project not exists in the context")
+	}
+
+	funcId := c.Param("functionId")
+	version := c.Param("version")
+
+	var functionEntity *models.Function
+	err := DB.
+		Where("function_id = ? AND version = ? AND project_id = ?", funcId, version, project.ID.String()).
+		Preload("Project").
+		First(&functionEntity).
+		Error
+
+	return functionEntity, err
+}
+
+// GetAliasFromContextParams retrieves the alias of the function in the context params based on the given alias name.
+func GetAliasFromContextParams(c *gin.Context) (*models.Alias, error) {
+	project, exists := GetProjectFromContext(c)
+	if !exists {
+		return nil, fmt.Errorf("project not exists in the context")
+	}
+
+	var aliasEntity *models.Alias
+	err := DB.
+		Where("function_id = ? AND name = ? AND project_id = ?", c.Param("functionId"), c.Param("alias"), project.ID.String()).
+		First(&aliasEntity).
+		Error
+
+	return aliasEntity, err
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/docker/go-units"
+)
+
+// GetEnvDuration reads a duration such as "5m" from the environment.
+// It falls back to the given default when the variable is unset or invalid.
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil || value <= 0 {
+		Logger.Warnf("invalid duration %q for %s, using %s", raw, key, fallback)
+		return fallback
+	}
+
+	return value
+}
+
+// GetEnvInt reads an integer from the environment.
+// It falls back to the given default when the variable is unset or invalid.
+func GetEnvInt(key string, fallback int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		Logger.Warnf("invalid integer %q for %s, using %d", raw, key, fallback)
+		return fallback
+	}
+
+	return value
+}
+
+// GetEnvBytes reads a size such as "256m" from the environment.
+// It falls back to the given default when the variable is unset or invalid.
+func GetEnvBytes(key string, fallback int64) int64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	value, err := units.RAMInBytes(raw)
+	if err != nil || value < 0 {
+		Logger.Warnf("invalid size %q for %s, using %d bytes", raw, key, fallback)
+		return fallback
+	}
+
+	return value
+}
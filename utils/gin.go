@@ -1,6 +1,16 @@
 package utils
 
-import "github.com/gin-gonic/gin"
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
 
 func JsonError(ctx *gin.Context, code int, err error, help string) {
 	ctx.AbortWithStatusJSON(code, gin.H{
@@ -25,3 +35,18 @@ func HandleError(c *gin.Context, statusCode int, err error, message string) bool
 	}
 	return false
 }
+
+// GetPagination reads the page, starting at 1, and pageSize query parameters.
+func GetPagination(c *gin.Context) (int, int, error) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		return 0, 0, fmt.Errorf("page must be a positive integer")
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxPageSize {
+		return 0, 0, fmt.Errorf("pageSize must be an integer between 1 and %d", maxPageSize)
+	}
+
+	return page, pageSize, nil
+}
@@ -36,6 +36,11 @@ func initMinio() {
 }
 
 func UploadToMinIO(filePath string, name string) (minio.UploadInfo, error) {
+	return UploadFileToMinIO(filePath, name, "application/x-gzip")
+}
+
+// UploadFileToMinIO uploads the file at the given path under the given key.
+func UploadFileToMinIO(filePath string, name string, contentType string) (minio.UploadInfo, error) {
 	ctx := context.Background()
 	err := minioClient.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: location})
 
@@ -43,18 +48,79 @@ func UploadToMinIO(filePath string, name string) (minio.UploadInfo, error) {
 		// Check to see if we already own this bucket (which happens if you run this twice)
 		exists, errBucketExists := minioClient.BucketExists(ctx, bucket)
 		if errBucketExists != nil || !exists {
-			Logger.Fatalln(err)
+			return minio.UploadInfo{}, err
 		}
 	}
 
-	contentType := "application/x-gzip"
-
 	info, err := minioClient.FPutObject(ctx, bucket, name, filePath, minio.PutObjectOptions{ContentType: contentType})
 
 	return info, err
 }
 
+// GetFromMinIO returns the object stored under the given key, along with its size and content type.
+func GetFromMinIO(key string) (*minio.Object, minio.ObjectInfo, error) {
+	ctx := context.Background()
+	object, err := minioClient.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, minio.ObjectInfo{}, err
+	}
+
+	info, err := object.Stat()
+	if err != nil {
+		_ = object.Close()
+		return nil, minio.ObjectInfo{}, err
+	}
+
+	return object, info, nil
+}
+
+// DownloadFromMinIO writes the object stored under the given key to the given path.
+func DownloadFromMinIO(key string, filePath string) error {
+	ctx := context.Background()
+	return minioClient.FGetObject(ctx, bucket, key, filePath, minio.GetObjectOptions{})
+}
+
+// ListMinIO returns the keys of the objects stored under the given prefix.
+func ListMinIO(prefix string) ([]string, error) {
+	ctx := context.Background()
+
+	var keys []string
+	for object := range minioClient.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		keys = append(keys, object.Key)
+	}
+
+	return keys, nil
+}
+
+// IsNotFoundMinIO reports whether the error is about a missing object or bucket.
+func IsNotFoundMinIO(err error) bool {
+	code := minio.ToErrorResponse(err).Code
+	return code == "NoSuchKey" || code == "NoSuchBucket"
+}
+
 func DeleteFromMinIO(key string) error {
 	ctx := context.Background()
 	return minioClient.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{ForceDelete: true})
 }
+
+// DeletePrefixFromMinIO deletes every object stored under the given prefix.
+func DeletePrefixFromMinIO(prefix string) error {
+	keys, err := ListMinIO(prefix)
+	if err != nil {
+		if IsNotFoundMinIO(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, key := range keys {
+		if err = DeleteFromMinIO(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+// manifestMediaTypes are the manifest formats accepted when resolving a tag to its digest.
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// RegistryAddress returns the host:port of the image registry bundled with the
+// engine, or an empty string when no registry is configured.
+func RegistryAddress() string {
+	port := os.Getenv("DOCKER_REGISTRY_PORT")
+	if port == "" {
+		return ""
+	}
+
+	host := os.Getenv("DOCKER_REGISTRY_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+
+	return fmt.Sprintf("%s:%s", host, port)
+}
+
+// RegistryReference returns the reference of a local image name in the bundled registry.
+func RegistryReference(image string) string {
+	return fmt.Sprintf("%s/%s", RegistryAddress(), image)
+}
+
+// RegistryAuth returns the encoded credentials sent to the bundled registry.
+// It holds no credentials, but the daemon refuses pushes without the header.
+func RegistryAuth() string {
+	auth, err := registry.EncodeAuthConfig(registry.AuthConfig{})
+	if err != nil {
+		Logger.Warnf("cannot encode the registry credentials: %v", err)
+	}
+	return auth
+}
+
+// DeleteRegistryManifest deletes the manifest a tag points at in the bundled registry,
+// which must run with deletion enabled. Every tag of the repository pointing at the
+// same manifest goes away with it. A missing tag or registry is not an error.
+func DeleteRegistryManifest(repository string, tag string) error {
+	address := RegistryAddress()
+	if address == "" {
+		return nil
+	}
+
+	manifestsURL := fmt.Sprintf("http://%s/v2/%s/manifests/", address, repository)
+
+	req, err := http.NewRequest(http.MethodHead, manifestsURL+tag, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cannot resolve %s:%s in the registry: %s", repository, tag, resp.Status)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return fmt.Errorf("the registry did not return the digest of %s:%s", repository, tag)
+	}
+
+	req, err = http.NewRequest(http.MethodDelete, manifestsURL+digest, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("cannot delete %s:%s from the registry: %s", repository, tag, resp.Status)
+	}
+
+	return nil
+}
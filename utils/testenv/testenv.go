@@ -0,0 +1,33 @@
+// Package testenv runs the tests of the packages depending on utils from the root
+// of the module, where lives the .env file utils loads when it is initialized. The
+// tests import it for its side effect only: as it depends on no other package of
+// the module, it is initialized before utils.
+package testenv
+
+import (
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	dir, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			break
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			panic("the go.mod of the module cannot be found")
+		}
+		dir = parent
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+}